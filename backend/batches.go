@@ -0,0 +1,434 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 1バッチで登録できる動画数の上限
+var maxBatchVideos = getEnvInt("BATCH_MAX_VIDEOS", 200)
+
+// プレイリスト・チャンネル・URL一覧の一括処理
+type Batch struct {
+	ID        string         `json:"id"`
+	SourceURL string         `json:"source_url,omitempty"` // 展開元のプレイリスト/チャンネルURL
+	VideoIDs  []string       `json:"video_ids"`
+	Skipped   []batchSkipped `json:"skipped,omitempty"` // 事前チェックで除外した動画
+	CreatedAt string         `json:"created_at"`
+}
+
+// 事前チェックで除外した動画
+type batchSkipped struct {
+	URL     string `json:"url"`
+	Title   string `json:"title,omitempty"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// バッチの集計状況
+type batchStatus struct {
+	Batch
+	Status string         `json:"status"` // processing, completed, partial, error
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"` // 動画ステータスごとの件数
+	Quota  batchQuota     `json:"quota"`
+	Videos []Video        `json:"videos"`
+}
+
+// バッチのSpeech-to-Text使用量見込み
+type batchQuota struct {
+	TotalMinutes         int  `json:"total_minutes"`          // 長さが分かっている動画の合計（未処理分のみ）
+	UnknownDurationCount int  `json:"unknown_duration_count"` // 長さが不明な動画数
+	RemainingMinutes     int  `json:"remaining_minutes"`      // 今月の残り時間
+	Exceeds              bool `json:"exceeds"`                // 残り時間を超える見込みか
+}
+
+// yt-dlp --flat-playlist の1エントリ
+type playlistEntry struct {
+	ID           string  `json:"id"`
+	URL          string  `json:"url"`
+	WebpageURL   string  `json:"webpage_url"`
+	Title        string  `json:"title"`
+	Channel      string  `json:"channel"`
+	Uploader     string  `json:"uploader"`
+	Duration     float64 `json:"duration"`
+	LiveStatus   string  `json:"live_status"`
+	Availability string  `json:"availability"`
+	AgeLimit     int     `json:"age_limit"` // --flat-playlistでは通常含まれない
+}
+
+// バッチテーブル（muで保護）
+var batches = []Batch{}
+
+// POST /batches - プレイリスト・チャンネル・URL一覧を一括登録
+func createBatch(c *gin.Context) {
+	var req struct {
+		URL         string        `json:"url"`  // プレイリスト/チャンネルURL
+		URLs        []string      `json:"urls"` // 動画URLの一覧
+		UseCaptions *bool         `json:"use_captions"`
+		Ytdlp       *YtdlpOptions `json:"ytdlp"` // yt-dlpオプションの上書き（全動画に適用）
+		SpeechAPI   string        `json:"speech_api"`
+		SpeechModel string        `json:"speech_model"`
+		UseEnhanced *bool         `json:"use_enhanced"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sources := req.URLs
+	if req.URL != "" {
+		sources = append([]string{req.URL}, sources...)
+	}
+	if len(sources) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "urlまたはurlsを指定してください"})
+		return
+	}
+	if err := req.Ytdlp.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "reason": "invalid_ytdlp_options"})
+		return
+	}
+	ytdlpOpts := VideoOptions{Ytdlp: req.Ytdlp}.ytdlp()
+	speechOverrides := VideoOptions{SpeechAPI: req.SpeechAPI, SpeechModel: req.SpeechModel, UseEnhanced: req.UseEnhanced}
+	if err := speechOverrides.speech().validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "reason": "invalid_speech_options"})
+		return
+	}
+
+	// yt-dlpで各URLを動画単位に展開（重複は除外）
+	var entries []playlistEntry
+	seen := map[string]bool{}
+	for _, src := range sources {
+		expanded, err := expandPlaylist(c.Request.Context(), src, ytdlpOpts)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("URLの展開に失敗しました（%s）: %v", src, err)})
+			return
+		}
+		for _, e := range expanded {
+			if !seen[e.URL] {
+				seen[e.URL] = true
+				entries = append(entries, e)
+			}
+		}
+	}
+	if len(entries) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "処理できる動画がありません"})
+		return
+	}
+	if len(entries) > maxBatchVideos {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("動画数が上限を超えています（%d件 > %d件）", len(entries), maxBatchVideos)})
+		return
+	}
+
+	batch := Batch{
+		ID:        uuid.New().String(),
+		SourceURL: req.URL,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+
+	var children []Video
+	for _, e := range entries {
+		opts := VideoOptions{
+			UseCaptions: preferYoutubeCaptions,
+			Ytdlp:       req.Ytdlp,
+			SpeechAPI:   req.SpeechAPI,
+			SpeechModel: req.SpeechModel,
+			UseEnhanced: req.UseEnhanced,
+		}
+		if req.UseCaptions != nil {
+			opts.UseCaptions = *req.UseCaptions
+		}
+
+		// 展開結果で分かる範囲（長さ・ライブ・公開範囲）で事前チェックし、処理できない動画は除外
+		if perr := checkVideoInfo(e.info(), opts); perr != nil {
+			log.Printf("バッチから除外: %s (%s)", e.URL, perr.Message)
+			batch.Skipped = append(batch.Skipped, batchSkipped{URL: e.URL, Title: e.Title, Reason: perr.Reason, Message: perr.Message})
+			continue
+		}
+
+		video := Video{
+			ID:         uuid.New().String(),
+			YoutubeUrl: e.URL,
+			Status:     "queued",
+			BatchID:    batch.ID,
+			Options:    opts,
+			Metadata:   e.metadata(),
+			CreatedAt:  time.Now().Format(time.RFC3339),
+			UpdateAt:   time.Now().Format(time.RFC3339),
+		}
+		children = append(children, video)
+		batch.VideoIDs = append(batch.VideoIDs, video.ID)
+	}
+
+	if len(children) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "処理できる動画がありません", "reason": "all_skipped", "skipped": batch.Skipped})
+		return
+	}
+
+	mu.Lock()
+	videos = append(videos, children...)
+	batches = append(batches, batch)
+	status := buildBatchStatus(batch)
+	mu.Unlock()
+
+	log.Printf("バッチ登録: BatchID=%s, 動画数=%d, 除外=%d", batch.ID, len(children), len(batch.Skipped))
+	goJob(func() { processBatch(batch, children, os.Getenv("GEMINI_API_KEY")) })
+
+	c.JSON(http.StatusCreated, status)
+}
+
+// GET /batches - バッチ一覧
+func getBatches(c *gin.Context) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	result := []batchStatus{}
+	for _, batch := range batches {
+		result = append(result, buildBatchStatus(batch))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GET /batches/:id - バッチの集計状況
+func getBatch(c *gin.Context) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	batch, ok := findBatch(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Batch not found"})
+		return
+	}
+	c.JSON(http.StatusOK, buildBatchStatus(batch))
+}
+
+// GET /batches/:id/download - バッチ内の字幕・翻訳をZIPでまとめてダウンロード
+// クエリは GET /videos/:id/transcript/export と同じ（mark_low_confidence, threshold, offset, stretch, from_fps, to_fps）
+func downloadBatch(c *gin.Context) {
+	opts, ok := bindExportOptions(c)
+	if !ok {
+		return
+	}
+
+	mu.Lock()
+	batch, ok := findBatch(c.Param("id"))
+	if !ok {
+		mu.Unlock()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Batch not found"})
+		return
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := 0
+	for i, videoID := range batch.VideoIDs {
+		name := fmt.Sprintf("%03d_%s", i+1, videoID)
+		for _, transcript := range transcripts {
+			if transcript.VideoId == videoID {
+				if err := addZipFile(zw, name+"."+transcript.Language+".srt", renderExportSRT(transcript.Segments, opts)); err != nil {
+					mu.Unlock()
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				files++
+			}
+		}
+		if idx := findTranslationIndexByVideo(videoID); idx >= 0 {
+			tr := translations[idx]
+			if err := addZipFile(zw, name+"."+tr.TargetLang+".srt", renderExportSRT(tr.Segments, opts)); err != nil {
+				mu.Unlock()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			files++
+		}
+	}
+	mu.Unlock()
+
+	if files == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ダウンロードできる字幕がまだありません"})
+		return
+	}
+	if err := zw.Close(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="batch_%s.zip"`, batch.ID))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// ZIPにテキストファイルを追加
+func addZipFile(zw *zip.Writer, name, content string) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("ZIP作成エラー: %v", err)
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		return fmt.Errorf("ZIP書き込みエラー: %v", err)
+	}
+	return nil
+}
+
+// バッチ内の動画を順番に処理（同時に大量のダウンロード・API呼び出しを行わないように）
+func processBatch(batch Batch, children []Video, apiKey string) {
+	for _, v := range children {
+		updateVideoStatus(v.ID, "processing")
+
+		// 展開結果に無い項目（年齢制限・正確な長さ）は処理直前に単体の動画と同じ事前チェックで確認
+		info, perr := preflightVideo(context.Background(), v.YoutubeUrl, v.Options)
+		if perr != nil {
+			failVideo(v.ID, perr.Reason, perr.Message)
+			log.Printf("事前チェック失敗: VideoID=%s, %s", v.ID, perr.Message)
+			continue
+		}
+		v.Metadata = info.metadata()
+		updateVideoMetadata(v.ID, v.Metadata)
+
+		processVideo(v, apiKey)
+	}
+	log.Printf("バッチ処理完了: BatchID=%s", batch.ID)
+}
+
+// バッチを検索（呼び出し側でmuをロック済み）
+func findBatch(id string) (Batch, bool) {
+	for _, batch := range batches {
+		if batch.ID == id {
+			return batch, true
+		}
+	}
+	return Batch{}, false
+}
+
+// バッチの集計状況を作成（呼び出し側でmuをロック済み）
+func buildBatchStatus(batch Batch) batchStatus {
+	status := batchStatus{
+		Batch:  batch,
+		Total:  len(batch.VideoIDs),
+		Counts: map[string]int{},
+		Videos: []Video{},
+	}
+
+	member := map[string]bool{}
+	for _, id := range batch.VideoIDs {
+		member[id] = true
+	}
+	for _, v := range videos {
+		if !member[v.ID] {
+			continue
+		}
+		status.Videos = append(status.Videos, v)
+		status.Counts[v.Status]++
+
+		// 未処理の動画だけを使用量見込みに計上
+		if v.Status == "queued" || v.Status == "processing" {
+			if minutes := v.speechMinutes(); minutes > 0 {
+				status.Quota.TotalMinutes += minutes
+			} else {
+				status.Quota.UnknownDurationCount++
+			}
+		}
+	}
+
+	status.Quota.RemainingMinutes = remainingSpeechMinutes()
+	status.Quota.Exceeds = status.Quota.TotalMinutes > status.Quota.RemainingMinutes
+
+	switch {
+	case status.Counts["queued"]+status.Counts["processing"] > 0:
+		status.Status = "processing"
+	case status.Counts["error"] == status.Total:
+		status.Status = "error"
+	case status.Counts["error"] > 0:
+		status.Status = "partial"
+	default:
+		status.Status = "completed"
+	}
+	return status
+}
+
+// yt-dlpでプレイリスト・チャンネルURLを動画エントリに展開（単一の動画URLはそのまま1件）
+func expandPlaylist(ctx context.Context, rawURL string, opts YtdlpOptions) ([]playlistEntry, error) {
+	out, err := runYtdlp(ctx, ytdlpMetadataTimeout, append(opts.args(),
+		"--flat-playlist",
+		"--dump-json",
+		channelVideosURL(rawURL),
+	), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []playlistEntry
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e playlistEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("プレイリスト解析エラー: %v", err)
+		}
+		switch {
+		case e.WebpageURL != "":
+			e.URL = e.WebpageURL
+		case e.URL == "" && e.ID != "":
+			e.URL = "https://www.youtube.com/watch?v=" + e.ID
+		}
+		if e.URL != "" {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// チャンネルのトップURL（/@name など）は動画タブのURLに変換
+func channelVideosURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	isChannel := len(parts) == 1 && strings.HasPrefix(parts[0], "@") ||
+		len(parts) == 2 && (parts[0] == "channel" || parts[0] == "c" || parts[0] == "user")
+	if isChannel {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/videos"
+		return u.String()
+	}
+	return rawURL
+}
+
+// プレイリストのエントリを事前チェック用の動画情報に変換
+func (e playlistEntry) info() *ytdlpInfo {
+	return &ytdlpInfo{
+		Title:        e.Title,
+		Channel:      e.Channel,
+		Uploader:     e.Uploader,
+		Duration:     e.Duration,
+		LiveStatus:   e.LiveStatus,
+		Availability: e.Availability,
+		AgeLimit:     e.AgeLimit,
+	}
+}
+
+// プレイリストのエントリをVideoMetadataに変換
+func (e playlistEntry) metadata() *VideoMetadata {
+	channel := e.Channel
+	if channel == "" {
+		channel = e.Uploader
+	}
+	return &VideoMetadata{
+		Title:    e.Title,
+		Channel:  channel,
+		Duration: e.Duration,
+	}
+}
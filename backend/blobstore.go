@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// オブジェクトストレージの設定（環境変数で上書き可能）
+var (
+	audioStorageBucket     = getEnv("AUDIO_STORAGE_BUCKET", os.Getenv("GCS_BUCKET_NAME")) // 音声認識用の一時保存先のGCSバケット（Speech-to-TextはGCSしか読めないためGCSのみ）
+	artifactStorageBackend = getEnv("ARTIFACT_STORAGE_BACKEND", "")                       // 字幕・音声の保管先（gcs, local, s3、空なら保管しない）
+	artifactStorageBucket  = getEnv("ARTIFACT_STORAGE_BUCKET", "")                        // 保管先のバケット（localはディレクトリ）
+	archiveAudio           = getEnv("ARCHIVE_AUDIO", "false") == "true"                   // 音声ファイルも保管するか
+
+	s3Endpoint  = getEnv("S3_ENDPOINT", "s3.amazonaws.com") // S3互換ストレージのエンドポイント（MinIOなら host:9000）
+	s3Region    = getEnv("S3_REGION", "")
+	s3AccessKey = getEnv("S3_ACCESS_KEY", "")
+	s3SecretKey = getEnv("S3_SECRET_KEY", "")
+	s3UseSSL    = getEnv("S3_USE_SSL", "true") == "true"
+)
+
+// 音声認識のリクエストに直接含められる音声の上限（Speech-to-Textの制限）
+const maxInlineAudioBytes = 10 * 1024 * 1024
+
+// ストレージの種類
+const (
+	storageGCS   = "gcs"
+	storageLocal = "local"
+	storageS3    = "s3"
+)
+
+// オブジェクトストレージ（GCS・ローカルディスク・S3互換）
+type blobStore interface {
+	// keyにデータを保存し、保存先のURI（gs://, s3://, file://）を返す
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error)
+	// keyのオブジェクトを削除
+	Delete(ctx context.Context, key string) error
+}
+
+// 起動時に作成（audioStoreは常にGCS、artifactStoreは保管しない設定ならnil）
+var (
+	audioStore    blobStore
+	artifactStore blobStore
+)
+
+// 設定に従ってストレージを作成（起動時に1回だけ呼ぶ）
+// 一時保存先と保管先は独立して作成し、片方が失敗してももう片方は使えるようにする
+func initBlobStores() error {
+	var errs []error
+	store, err := newBlobStore(storageGCS, audioStorageBucket)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("音声の一時保存先の作成エラー: %v", err))
+	} else {
+		audioStore = store
+	}
+	if artifactStorageBackend != "" {
+		store, err := newBlobStore(artifactStorageBackend, artifactStorageBucket)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("保管先の作成エラー: %v", err))
+		} else {
+			artifactStore = store
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// 種類とバケット（localの場合はディレクトリ）からストレージを作成
+func newBlobStore(backend, bucket string) (blobStore, error) {
+	switch backend {
+	case storageGCS:
+		if bucket == "" {
+			return nil, fmt.Errorf("GCSのバケット名が設定されていません")
+		}
+		return &gcsStore{bucket: bucket}, nil
+
+	case storageLocal:
+		if bucket == "" {
+			bucket = "storage"
+		}
+		return &localStore{dir: bucket}, nil
+
+	case storageS3:
+		if bucket == "" {
+			return nil, fmt.Errorf("S3のバケット名が設定されていません")
+		}
+		client, err := minio.New(s3Endpoint, &minio.Options{
+			Creds:  credentials.NewStaticV4(s3AccessKey, s3SecretKey, ""),
+			Secure: s3UseSSL,
+			Region: s3Region,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("S3クライアント作成エラー: %v", err)
+		}
+		return &s3Store{client: client, bucket: bucket}, nil
+
+	default:
+		return nil, fmt.Errorf("不明なストレージの種類です: %s（gcs, local, s3のいずれか）", backend)
+	}
+}
+
+// Google Cloud Storage（共有のStorageクライアントを使用）
+type gcsStore struct {
+	bucket string
+}
+
+func (s *gcsStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	client, err := storageClient()
+	if err != nil {
+		return "", err
+	}
+
+	w := client.Bucket(s.bucket).Object(key).NewWriter(ctx)
+	w.ContentType = contentType
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return "", fmt.Errorf("アップロードエラー: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("アップロード完了エラー: %v", err)
+	}
+	return fmt.Sprintf("gs://%s/%s", s.bucket, key), nil
+}
+
+func (s *gcsStore) Delete(ctx context.Context, key string) error {
+	client, err := storageClient()
+	if err != nil {
+		return err
+	}
+	if err := client.Bucket(s.bucket).Object(key).Delete(ctx); err != nil {
+		return fmt.Errorf("ファイル削除エラー: %v", err)
+	}
+	return nil
+}
+
+// ローカルディスク（dir配下にkeyのパスで保存）
+type localStore struct {
+	dir string
+}
+
+func (s *localStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("ディレクトリ作成エラー: %v", err)
+	}
+
+	// 書き込み途中のファイルが見えないよう一時ファイルに書いてからリネーム
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", fmt.Errorf("ファイル作成エラー: %v", err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", fmt.Errorf("ファイル書き込みエラー: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("ファイル書き込みエラー: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("ファイル保存エラー: %v", err)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String(), nil
+}
+
+func (s *localStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("ファイル削除エラー: %v", err)
+	}
+	return nil
+}
+
+// keyをdir配下のパスに変換（dirの外を指すkeyは拒否）
+func (s *localStore) path(key string) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	rel, err := filepath.Rel(s.dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("不正なキーです: %s", key)
+	}
+	return path, nil
+}
+
+// S3互換ストレージ（AWS S3・MinIOなど）
+type s3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	// サイズ不明（-1）の場合はマルチパートで送信される
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, -1, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return "", fmt.Errorf("アップロードエラー: %v", err)
+	}
+	return fmt.Sprintf("s3://%s/%s", s.bucket, key), nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("ファイル削除エラー: %v", err)
+	}
+	return nil
+}
+
+// ファイルをストレージに保存
+func putFile(ctx context.Context, store blobStore, key, path, contentType string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("ファイル読み込みエラー: %v", err)
+	}
+	defer f.Close()
+	return store.Put(ctx, key, f, contentType)
+}
+
+// 字幕・翻訳（と音声）を保管先に保存し、URIを動画に記録
+// 保管はおまけの処理なので、失敗してもログに残すだけで処理は続ける
+func archiveArtifacts(ctx context.Context, videoID string, files map[string]string) {
+	if artifactStore == nil || len(files) == 0 {
+		return
+	}
+
+	uris := map[string]string{}
+	for name, content := range files {
+		key := fmt.Sprintf("videos/%s/%s", videoID, name)
+		uri, err := artifactStore.Put(ctx, key, strings.NewReader(content), contentTypeFor(name))
+		if err != nil {
+			log.Printf("保管エラー（続行）: VideoID=%s, %s: %v", videoID, name, err)
+			continue
+		}
+		uris[name] = uri
+	}
+	recordArtifacts(videoID, uris)
+}
+
+// 音声ファイルを保管先に保存（ARCHIVE_AUDIO=trueの場合のみ）
+func archiveAudioFile(ctx context.Context, videoID, audioFile string) {
+	if artifactStore == nil || !archiveAudio {
+		return
+	}
+	name := filepath.Base(audioFile)
+	uri, err := putFile(ctx, artifactStore, fmt.Sprintf("videos/%s/%s", videoID, name), audioFile, contentTypeFor(name))
+	if err != nil {
+		log.Printf("音声の保管エラー（続行）: VideoID=%s: %v", videoID, err)
+		return
+	}
+	recordArtifacts(videoID, map[string]string{name: uri})
+}
+
+// 保管したファイルのURIを動画に記録
+func recordArtifacts(videoID string, uris map[string]string) {
+	if len(uris) == 0 {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i, video := range videos {
+		if video.ID == videoID {
+			// コピーした動画とマップを共有しないよう作り直す
+			artifacts := map[string]string{}
+			for name, uri := range videos[i].Artifacts {
+				artifacts[name] = uri
+			}
+			for name, uri := range uris {
+				artifacts[name] = uri
+			}
+			videos[i].Artifacts = artifacts
+			break
+		}
+	}
+}
+
+// 拡張子からContent-Typeを決める
+func contentTypeFor(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".srt":
+		return "application/x-subrip"
+	case ".vtt":
+		return "text/vtt"
+	case ".mp3":
+		return "audio/mpeg"
+	case ".flac":
+		return "audio/flac"
+	case ".wav":
+		return "audio/wav"
+	default:
+		return "application/octet-stream"
+	}
+}
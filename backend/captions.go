@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 字幕の取得元
+const (
+	sourceSpeech          = "speech"                // Google Speech-to-Textで文字起こし
+	sourceYoutubeCaptions = "youtube_captions"      // 投稿者がアップロードした字幕
+	sourceYoutubeAuto     = "youtube_auto_captions" // YouTubeの自動生成字幕
+	sourceImport          = "import"                // ファイルから取り込み
+)
+
+// YouTube字幕取得の設定（環境変数で上書き可能）
+var (
+	preferYoutubeCaptions = getEnv("YOUTUBE_CAPTIONS_ENABLED", "false") == "true" // 音声認識の前に字幕取得を試すか（デフォルト）
+	allowAutoCaptions     = getEnv("YOUTUBE_AUTO_CAPTIONS_ENABLED", "true") == "true"
+	captionLangs          = getEnv("YOUTUBE_CAPTION_LANGS", "en.*,en")
+	minCaptionSegments    = getEnvInt("YOUTUBE_CAPTION_MIN_SEGMENTS", 3) // これ未満の字幕は不十分とみなす
+)
+
+// yt-dlpでYouTubeの字幕を取得してセグメントと取得元・言語を返す
+// 投稿者の字幕を優先し、なければ自動生成字幕を使う。どちらも使えない場合はエラーを返す
+func fetchYoutubeCaptions(ctx context.Context, v Video, dir string) ([]SubtitleSegment, string, string, error) {
+	segments, lang, err := downloadCaptions(ctx, v, dir, "--write-subs")
+	if err == nil {
+		return segments, sourceYoutubeCaptions, lang, nil
+	}
+	log.Printf("投稿者字幕なし: VideoID=%s, 理由=%v", v.ID, err)
+
+	if !allowAutoCaptions {
+		return nil, "", "", err
+	}
+
+	segments, lang, err = downloadCaptions(ctx, v, dir, "--write-auto-subs")
+	if err != nil {
+		return nil, "", "", err
+	}
+	return dedupeRollingCaptions(segments), sourceYoutubeAuto, lang, nil
+}
+
+// 指定した種類の字幕をVTTでダウンロードして解析し、ファイル名（<prefix>.<言語>.vtt）の言語と合わせて返す
+func downloadCaptions(ctx context.Context, v Video, dir, subsFlag string) ([]SubtitleSegment, string, error) {
+	prefix := filepath.Join(dir, v.ID+".captions")
+	defer removeCaptionFiles(prefix)
+
+	_, err := runYtdlp(ctx, ytdlpMetadataTimeout, append(v.Options.ytdlp().args(),
+		"--skip-download",
+		subsFlag,
+		"--sub-langs", captionLangs,
+		"--sub-format", "vtt",
+		"-o", prefix,
+		v.YoutubeUrl,
+	), nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	files, _ := filepath.Glob(prefix + ".*.vtt")
+	if len(files) == 0 {
+		return nil, "", fmt.Errorf("字幕ファイルがありません")
+	}
+	lang := strings.TrimSuffix(strings.TrimPrefix(files[0], prefix+"."), ".vtt")
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		return nil, "", fmt.Errorf("字幕ファイル読み込みエラー: %v", err)
+	}
+
+	segments, _, err := parseSubtitleFile(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(segments) < minCaptionSegments {
+		return nil, "", fmt.Errorf("字幕が少なすぎます（%d件）", len(segments))
+	}
+	return segments, lang, nil
+}
+
+// ダウンロードした字幕ファイルを削除
+func removeCaptionFiles(prefix string) {
+	files, _ := filepath.Glob(prefix + ".*")
+	for _, f := range files {
+		os.Remove(f)
+	}
+}
+
+// 自動生成字幕の重複を除去
+// YouTubeの自動字幕は前のキューの行を繰り返しながら1行ずつ流れるため、
+// 前のキューと重なるテキストと極端に短いキューを取り除く
+func dedupeRollingCaptions(segments []SubtitleSegment) []SubtitleSegment {
+	var result []SubtitleSegment
+	prevText := ""
+	for _, seg := range segments {
+		if seg.EndTime-seg.StartTime < 0.05 {
+			continue
+		}
+
+		text := seg.Text
+		if prevText != "" && strings.HasPrefix(text, prevText) {
+			text = strings.TrimSpace(strings.TrimPrefix(text, prevText))
+		}
+		if text == "" {
+			continue
+		}
+		prevText = text
+
+		seg.Text = text
+		result = append(result, seg)
+	}
+	return result
+}
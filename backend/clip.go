@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// 切り出し範囲が指定されているか
+func (o VideoOptions) HasClip() bool {
+	return o.ClipStart > 0 || o.ClipEnd > 0
+}
+
+// 切り出し範囲を検証（durationは動画の長さ、不明な場合は0）
+func (o VideoOptions) validateClip(duration float64) error {
+	if !o.HasClip() {
+		return nil
+	}
+	if o.ClipStart < 0 || o.ClipEnd < 0 {
+		return fmt.Errorf("start_time・end_timeは0以上で指定してください")
+	}
+	if o.ClipEnd > 0 && o.ClipEnd <= o.ClipStart {
+		return fmt.Errorf("end_time（%.1f秒）はstart_time（%.1f秒）より後を指定してください", o.ClipEnd, o.ClipStart)
+	}
+	if duration > 0 && o.ClipStart >= duration {
+		return fmt.Errorf("start_time（%.1f秒）が動画の長さ（%.1f秒）を超えています", o.ClipStart, duration)
+	}
+	return nil
+}
+
+// 切り出し範囲の終了位置（未指定・動画の長さ超過の場合は動画の終わり）
+func (o VideoOptions) clipEnd(duration float64) float64 {
+	if o.ClipEnd > 0 && (duration <= 0 || o.ClipEnd < duration) {
+		return o.ClipEnd
+	}
+	return duration
+}
+
+// 処理対象となる長さ（秒）。切り出し範囲があればその長さ、不明な場合は0
+func (o VideoOptions) processedSeconds(duration float64) float64 {
+	if !o.HasClip() {
+		return duration
+	}
+	end := o.clipEnd(duration)
+	if end <= 0 {
+		return 0
+	}
+	return end - o.ClipStart
+}
+
+// 音声認識の対象となる長さ（秒、不明な場合は0）
+func (v Video) speechSeconds() float64 {
+	duration := 0.0
+	if v.Metadata != nil {
+		duration = v.Metadata.Duration
+	}
+	return v.Options.processedSeconds(duration)
+}
+
+// 音声認識の使用量として計上する分数（切り上げ、不明な場合は0）
+func (v Video) speechMinutes() int {
+	seconds := v.speechSeconds()
+	if seconds <= 0 {
+		return 0
+	}
+	return int(math.Ceil(seconds / 60))
+}
+
+// yt-dlpの --download-sections 用の範囲指定（例: "*720-1080"）
+func (o VideoOptions) downloadSection() string {
+	if o.ClipEnd > 0 {
+		return fmt.Sprintf("*%.3f-%.3f", o.ClipStart, o.ClipEnd)
+	}
+	return fmt.Sprintf("*%.3f-inf", o.ClipStart)
+}
+
+// セグメントの時刻をずらす（切り出した音声の時刻を元動画の時刻に戻す）
+func shiftSegments(segments []SubtitleSegment, offset float64) []SubtitleSegment {
+	shifted := make([]SubtitleSegment, len(segments))
+	for i, seg := range segments {
+		seg.StartTime += offset
+		seg.EndTime += offset
+		if seg.Words != nil {
+			words := make([]WordInfo, len(seg.Words))
+			for j, w := range seg.Words {
+				w.StartTime += offset
+				w.EndTime += offset
+				words[j] = w
+			}
+			seg.Words = words
+		}
+		shifted[i] = seg
+	}
+	return shifted
+}
+
+// 切り出し範囲と重なるセグメントだけを残し、範囲外にはみ出した部分を切り詰める
+func clipSegments(segments []SubtitleSegment, start, end float64) []SubtitleSegment {
+	var result []SubtitleSegment
+	for _, seg := range segments {
+		if seg.EndTime <= start || (end > 0 && seg.StartTime >= end) {
+			continue
+		}
+		if seg.StartTime < start {
+			seg.StartTime = start
+		}
+		if end > 0 && seg.EndTime > end {
+			seg.EndTime = end
+		}
+		seg.Words = wordsBetween(seg.Words, seg.StartTime, seg.EndTime)
+		result = append(result, seg)
+	}
+	return result
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 信頼度の確認に関する設定（環境変数で上書き可能）
+var (
+	reviewConfidenceThreshold  = getEnvFloat("REVIEW_CONFIDENCE_THRESHOLD", 0.8)            // これ未満の信頼度を要確認とする
+	lowConfidenceMarker        = getEnv("LOW_CONFIDENCE_MARKER", "[?]")                     // 書き出し時に要確認のセグメントの先頭に付ける印
+	exportLowConfidenceMarkers = getEnv("EXPORT_LOW_CONFIDENCE_MARKERS", "false") == "true" // 保管する字幕にも印を付けるか
+)
+
+// 要確認のセグメント
+type reviewSegment struct {
+	Index        int                  `json:"index"` // 1始まり（PATCH /videos/:id/transcript/segments/:n の番号）
+	StartTime    float64              `json:"start_time"`
+	EndTime      float64              `json:"end_time"`
+	Text         string               `json:"text"`
+	Confidence   float64              `json:"confidence"`
+	LowWords     []WordInfo           `json:"low_words,omitempty"`    // 信頼度がしきい値未満の単語
+	Alternatives []SegmentAlternative `json:"alternatives,omitempty"` // 音声認識の他の候補
+}
+
+// GET /videos/:id/transcript/review - 信頼度の低いセグメント一覧
+// クエリ: threshold（0〜1、省略時はREVIEW_CONFIDENCE_THRESHOLD）
+func reviewTranscript(c *gin.Context) {
+	id := c.Param("id")
+	threshold, err := confidenceThreshold(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "reason": "invalid_threshold"})
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, transcript := range transcripts {
+		if transcript.VideoId != id {
+			continue
+		}
+
+		review := []reviewSegment{}
+		scored := 0
+		for i, seg := range transcript.Segments {
+			if seg.Confidence <= 0 {
+				// 信頼度のないセグメント（字幕・インポート・手動修正）は対象外
+				continue
+			}
+			scored++
+			if !isLowConfidence(seg, threshold) {
+				continue
+			}
+			review = append(review, reviewSegment{
+				Index:        i + 1,
+				StartTime:    seg.StartTime,
+				EndTime:      seg.EndTime,
+				Text:         seg.Text,
+				Confidence:   seg.Confidence,
+				LowWords:     lowConfidenceWords(seg.Words, threshold),
+				Alternatives: seg.Alternatives,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"video_id":             id,
+			"transcript_id":        transcript.ID,
+			"threshold":            threshold,
+			"total_segments":       len(transcript.Segments),
+			"scored_segments":      scored,
+			"low_confidence_count": len(review),
+			"segments":             review,
+		})
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"error": "Transcript not found"})
+}
+
+// クエリのthresholdを読み取る（省略時は設定値）
+func confidenceThreshold(c *gin.Context) (float64, error) {
+	v := c.Query("threshold")
+	if v == "" {
+		return reviewConfidenceThreshold, nil
+	}
+	threshold, err := strconv.ParseFloat(v, 64)
+	if err != nil || threshold < 0 || threshold > 1 {
+		return 0, fmt.Errorf("thresholdは0〜1の数値で指定してください")
+	}
+	return threshold, nil
+}
+
+// 信頼度がしきい値未満か（信頼度が不明なセグメントは対象外）
+func isLowConfidence(seg SubtitleSegment, threshold float64) bool {
+	return seg.Confidence > 0 && seg.Confidence < threshold
+}
+
+// 信頼度がしきい値未満の単語
+func lowConfidenceWords(words []WordInfo, threshold float64) []WordInfo {
+	var low []WordInfo
+	for _, w := range words {
+		if w.Confidence > 0 && w.Confidence < threshold {
+			low = append(low, w)
+		}
+	}
+	return low
+}
+
+// 書き出し用に、信頼度の低いセグメントのテキストの先頭に印を付けたコピーを返す
+func markLowConfidence(segments []SubtitleSegment, threshold float64) []SubtitleSegment {
+	marked := make([]SubtitleSegment, len(segments))
+	for i, seg := range segments {
+		if isLowConfidence(seg, threshold) {
+			seg.Text = lowConfidenceMarker + " " + seg.Text
+		}
+		marked[i] = seg
+	}
+	return marked
+}
+
+// 開始〜終了の範囲に中央の時刻が入る単語
+func wordsBetween(words []WordInfo, start, end float64) []WordInfo {
+	var result []WordInfo
+	for _, w := range words {
+		mid := (w.StartTime + w.EndTime) / 2
+		if mid >= start && mid < end {
+			result = append(result, w)
+		}
+	}
+	return result
+}
+
+// 単語の信頼度の平均（単語に信頼度がない場合は元のセグメントの信頼度）
+func splitConfidence(words []WordInfo, fallback float64) float64 {
+	var sum float64
+	n := 0
+	for _, w := range words {
+		if w.Confidence > 0 {
+			sum += w.Confidence
+			n++
+		}
+	}
+	if n == 0 {
+		return fallback
+	}
+	return sum / float64(n)
+}
+
+// 結合したセグメントの単語情報と信頼度
+// 信頼度は低い方に合わせる（結合後も要確認の部分が含まれるため）
+func mergeConfidence(a, b SubtitleSegment) ([]WordInfo, float64) {
+	var words []WordInfo
+	if len(a.Words) > 0 && len(b.Words) > 0 {
+		words = append(append(words, a.Words...), b.Words...)
+	}
+	return words, lowerConfidence(a.Confidence, b.Confidence)
+}
+
+// 結合したセグメントの候補（片方の本文を候補に置き換えた文）
+// 信頼度はmergeConfidenceと同じく、組み合わせた2つのうち低い方に合わせる
+func mergeAlternatives(a, b SubtitleSegment, sep string) []SegmentAlternative {
+	var alternatives []SegmentAlternative
+	for _, alt := range a.Alternatives {
+		alternatives = appendAlternative(alternatives, alt.Text+sep+b.Text, lowerConfidence(alt.Confidence, b.Confidence))
+	}
+	for _, alt := range b.Alternatives {
+		alternatives = appendAlternative(alternatives, a.Text+sep+alt.Text, lowerConfidence(a.Confidence, alt.Confidence))
+	}
+	return alternatives
+}
+
+// 2つの信頼度の低い方（0は不明として扱い、もう一方を使う）
+func lowerConfidence(a, b float64) float64 {
+	switch {
+	case a <= 0:
+		return b
+	case b <= 0 || a < b:
+		return a
+	default:
+		return b
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
+)
+
+// .envの読み込み結果
+// パッケージ変数の初期化はmainより前に行われるため、getEnvから参照して
+// getEnvを使う設定値より先に.envが読み込まれるようにする
+var dotenvLoaded = loadDotenv()
+
+// .envを環境変数に読み込む
+func loadDotenv() bool {
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found")
+		return false
+	}
+	return true
+}
+
+// 環境変数を文字列で取得（未設定の場合はデフォルト値）
+func getEnv(key, def string) string {
+	_ = dotenvLoaded
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// 環境変数を整数で取得（未設定・不正な値の場合はデフォルト値）
+func getEnvInt(key string, def int) int {
+	v := getEnv(key, "")
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// 環境変数を小数で取得（未設定・不正な値の場合はデフォルト値）
+func getEnvFloat(key string, def float64) float64 {
+	v := getEnv(key, "")
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return def
+	}
+	return f
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+// 空き容量の取得に対応していない環境
+func freeDiskMB(path string) (uint64, error) {
+	return 0, errors.New("この環境では空き容量を取得できません")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 字幕の書き出しオプション
+type exportOptions struct {
+	MarkLowConfidence bool    // 信頼度の低いセグメントに印を付けるか
+	Threshold         float64 // 信頼度のしきい値
+	Retime            retimeOptions
+}
+
+// 保管する字幕の書き出しオプション（設定値に従う）
+func archiveExportOptions() exportOptions {
+	return exportOptions{MarkLowConfidence: exportLowConfidenceMarkers, Threshold: reviewConfidenceThreshold}
+}
+
+// クエリから書き出しオプションを読み取る（エラー時はレスポンス送信済み）
+// mark_low_confidence, threshold, offset, stretch, from_fps, to_fps
+func bindExportOptions(c *gin.Context) (exportOptions, bool) {
+	opts := exportOptions{MarkLowConfidence: c.Query("mark_low_confidence") == "true"}
+
+	threshold, err := confidenceThreshold(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "reason": "invalid_threshold"})
+		return opts, false
+	}
+	opts.Threshold = threshold
+
+	if err := c.ShouldBindQuery(&opts.Retime); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "reason": "invalid_retime_options"})
+		return opts, false
+	}
+	if err := opts.Retime.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "reason": "invalid_retime_options"})
+		return opts, false
+	}
+	return opts, true
+}
+
+// 書き出し用のSRT（時刻の補正と信頼度の印を適用）
+func renderExportSRT(segments []SubtitleSegment, opts exportOptions) string {
+	if opts.Retime.enabled() {
+		segments = retimeSegments(segments, opts.Retime)
+	}
+	if opts.MarkLowConfidence {
+		segments = markLowConfidence(segments, opts.Threshold)
+	}
+	return renderSRT(segments)
+}
+
+// GET /videos/:id/{transcript,translation}/export - SRTファイルとしてダウンロード
+// 保存済みの字幕は変えずに、書き出すファイルにだけオプションを適用する
+func exportDocument(docType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts, ok := bindExportOptions(c)
+		if !ok {
+			return
+		}
+
+		mu.Lock()
+		videoID := c.Param("id")
+		segments, lang, found := documentSegments(docType, videoID)
+		if !found {
+			mu.Unlock()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+		srt := renderExportSRT(segments, opts)
+		mu.Unlock()
+
+		name := fmt.Sprintf("%s.%s.srt", videoID, lang)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+		c.Data(http.StatusOK, contentTypeFor(name), []byte(srt))
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Gemini APIのモデルとエンドポイント
+const (
+	geminiModel    = "gemini-1.5-flash-latest"
+	geminiEndpoint = "https://generativelanguage.googleapis.com/v1beta/models/" + geminiModel + ":generateContent"
+)
+
+// Gemini APIリクエスト構造体
+type geminiRequest struct {
+	Contents         []geminiContent         `json:"contents"`
+	GenerationConfig *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+// 生成設定（JSONモード・レスポンススキーマ）
+type geminiGenerationConfig struct {
+	ResponseMimeType string        `json:"responseMimeType,omitempty"`
+	ResponseSchema   *geminiSchema `json:"responseSchema,omitempty"`
+	Temperature      *float64      `json:"temperature,omitempty"`
+}
+
+// レスポンススキーマ（OpenAPIスキーマのサブセット）
+type geminiSchema struct {
+	Type       string                   `json:"type"`
+	Items      *geminiSchema            `json:"items,omitempty"`
+	Properties map[string]*geminiSchema `json:"properties,omitempty"`
+	Required   []string                 `json:"required,omitempty"`
+}
+
+// Gemini APIレスポンス構造体
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+}
+
+// スキーマを指定してJSON形式の応答を要求する関数
+func callGeminiJSON(ctx context.Context, prompt string, schema *geminiSchema, apiKey string) (string, error) {
+	temperature := 0.2
+	return generateContent(ctx, geminiRequest{
+		Contents: []geminiContent{{Parts: []geminiPart{{Text: prompt}}}},
+		GenerationConfig: &geminiGenerationConfig{
+			ResponseMimeType: "application/json",
+			ResponseSchema:   schema,
+			Temperature:      &temperature,
+		},
+	}, apiKey)
+}
+
+// generateContentを呼び出し、最初の候補のテキストを返す
+func generateContent(ctx context.Context, req geminiRequest, apiKey string) (string, error) {
+	// APIキーはURLではなくヘッダーで送信（ログやプロキシに残らないように）
+	respBody, err := postJSONWithRetry(ctx, geminiEndpoint, map[string]string{"x-goog-api-key": apiKey}, req)
+	if err != nil {
+		return "", err
+	}
+
+	var res geminiResponse
+	if err := json.Unmarshal(respBody, &res); err != nil {
+		return "", fmt.Errorf("API応答解析エラー: %v", err)
+	}
+
+	if len(res.Candidates) == 0 {
+		if res.PromptFeedback != nil && res.PromptFeedback.BlockReason != "" {
+			return "", fmt.Errorf("プロンプトがブロックされました: %s", res.PromptFeedback.BlockReason)
+		}
+		return "", fmt.Errorf("API応答にcandidatesが含まれていません: %s", string(respBody))
+	}
+
+	candidate := res.Candidates[0]
+	if candidate.FinishReason == "MAX_TOKENS" {
+		return "", fmt.Errorf("出力トークン上限に達したため応答が途中で切れています")
+	}
+
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		text.WriteString(part.Text)
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("応答テキストが空です（finishReason=%s）", candidate.FinishReason)
+	}
+
+	return text.String(), nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	speech "cloud.google.com/go/speech/apiv1"
+	speechv2 "cloud.google.com/go/speech/apiv2"
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+// Google Cloudの認証情報の設定（どちらも未設定ならApplication Default Credentialsを使う）
+var (
+	googleCredentialsJSON = getEnv("GOOGLE_CREDENTIALS_JSON", "")  // サービスアカウントキーのJSON文字列
+	googleCredentialsFile = getEnv("GOOGLE_CREDENTIALS_FILE", "")  // サービスアカウントキーのファイルパス
+	googleCloudProject    = getEnv("GOOGLE_CLOUD_PROJECT", "")     // プロジェクトID（未設定なら認証情報のproject_id）
+	speechV2Location      = getEnv("SPEECH_V2_LOCATION", "global") // Speech-to-Text v2のリージョン（chirpなどはus-central1など）
+)
+
+// 起動時に作成して使い回すGoogle Cloudのクライアント
+type googleClients struct {
+	Speech    *speech.Client
+	SpeechV2  *speechv2.Client
+	Storage   *storage.Client
+	ProjectID string // Speech-to-Text v2のrecognizer名に使用
+}
+
+// 起動時に設定され、以降は読み取りのみ（初期化に失敗した場合はgcloudErrにエラー）
+var (
+	gcloud    *googleClients
+	gcloudErr error
+)
+
+// 認証情報の読み込み（インラインJSON → ファイル → ADCの順）
+// 2つ目の戻り値はログ用の認証情報の取得元、3つ目は認証情報に含まれるプロジェクトID
+func googleClientOptions() ([]option.ClientOption, string, string, error) {
+	switch {
+	case googleCredentialsJSON != "":
+		credentials, projectID, err := normalizeCredentialsJSON([]byte(googleCredentialsJSON))
+		if err != nil {
+			return nil, "", "", err
+		}
+		return []option.ClientOption{option.WithCredentialsJSON(credentials)}, "GOOGLE_CREDENTIALS_JSON", projectID, nil
+
+	case googleCredentialsFile != "":
+		data, err := os.ReadFile(googleCredentialsFile)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("認証ファイル読み込みエラー: %v", err)
+		}
+		credentials, projectID, err := normalizeCredentialsJSON(data)
+		if err != nil {
+			return nil, "", "", err
+		}
+		return []option.ClientOption{option.WithCredentialsJSON(credentials)}, "GOOGLE_CREDENTIALS_FILE", projectID, nil
+
+	default:
+		return nil, "Application Default Credentials", "", nil
+	}
+}
+
+// 認証JSONを解析し、private_keyの改行エスケープ（.envに1行で書いた場合など）を修正して再構築
+func normalizeCredentialsJSON(data []byte) ([]byte, string, error) {
+	var rawCredentials map[string]interface{}
+	if err := json.Unmarshal(data, &rawCredentials); err != nil {
+		return nil, "", fmt.Errorf("認証JSON解析エラー: %v", err)
+	}
+	projectID, _ := rawCredentials["project_id"].(string)
+
+	if privateKey, ok := rawCredentials["private_key"].(string); ok {
+		rawCredentials["private_key"] = strings.ReplaceAll(privateKey, "\\n", "\n")
+	}
+
+	credentialsBytes, err := json.Marshal(rawCredentials)
+	if err != nil {
+		return nil, "", fmt.Errorf("認証JSON再構築エラー: %v", err)
+	}
+	return credentialsBytes, projectID, nil
+}
+
+// Speech-to-TextとStorageのクライアントを作成（起動時に1回だけ呼ぶ）
+func initGoogleClients(ctx context.Context) error {
+	opts, source, projectID, err := googleClientOptions()
+	if err != nil {
+		gcloudErr = err
+		return err
+	}
+	if googleCloudProject != "" {
+		projectID = googleCloudProject
+	}
+
+	speechClient, err := speech.NewClient(ctx, opts...)
+	if err != nil {
+		gcloudErr = fmt.Errorf("Speech-to-Textクライアント作成エラー: %v", err)
+		return gcloudErr
+	}
+	// v2はリージョンごとのエンドポイントを使う
+	v2Opts := opts
+	if speechV2Location != "global" {
+		v2Opts = append(append([]option.ClientOption{}, opts...), option.WithEndpoint(speechV2Location+"-speech.googleapis.com:443"))
+	}
+	speechV2Client, err := speechv2.NewClient(ctx, v2Opts...)
+	if err != nil {
+		speechClient.Close()
+		gcloudErr = fmt.Errorf("Speech-to-Text v2クライアント作成エラー: %v", err)
+		return gcloudErr
+	}
+	storageClient, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		speechClient.Close()
+		speechV2Client.Close()
+		gcloudErr = fmt.Errorf("GCSクライアント作成エラー: %v", err)
+		return gcloudErr
+	}
+
+	gcloud = &googleClients{Speech: speechClient, SpeechV2: speechV2Client, Storage: storageClient, ProjectID: projectID}
+	log.Printf("Google Cloudクライアント作成完了（認証情報: %s）", source)
+	return nil
+}
+
+// 共有のSpeech-to-Textクライアント
+func speechClient() (*speech.Client, error) {
+	if gcloud == nil {
+		return nil, fmt.Errorf("Google Cloudクライアントが初期化されていません: %v", gcloudErr)
+	}
+	return gcloud.Speech, nil
+}
+
+// 共有のSpeech-to-Text v2クライアントとプロジェクトID
+func speechV2Client() (*speechv2.Client, string, error) {
+	if gcloud == nil {
+		return nil, "", fmt.Errorf("Google Cloudクライアントが初期化されていません: %v", gcloudErr)
+	}
+	if gcloud.ProjectID == "" {
+		return nil, "", fmt.Errorf("Speech-to-Text v2にはプロジェクトIDが必要です（GOOGLE_CLOUD_PROJECTを設定してください）")
+	}
+	return gcloud.SpeechV2, gcloud.ProjectID, nil
+}
+
+// 共有のStorageクライアント
+func storageClient() (*storage.Client, error) {
+	if gcloud == nil {
+		return nil, fmt.Errorf("Google Cloudクライアントが初期化されていません: %v", gcloudErr)
+	}
+	return gcloud.Storage, nil
+}
+
+// クライアントを閉じる（シャットダウン時）
+func closeGoogleClients() {
+	if gcloud == nil {
+		return
+	}
+	if err := gcloud.Speech.Close(); err != nil {
+		log.Printf("Speech-to-Textクライアント終了エラー: %v", err)
+	}
+	if err := gcloud.SpeechV2.Close(); err != nil {
+		log.Printf("Speech-to-Text v2クライアント終了エラー: %v", err)
+	}
+	if err := gcloud.Storage.Close(); err != nil {
+		log.Printf("GCSクライアント終了エラー: %v", err)
+	}
+}
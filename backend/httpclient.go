@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// 翻訳API用HTTPクライアントの設定（環境変数で上書き可能）
+var (
+	apiRequestTimeout = time.Duration(getEnvInt("TRANSLATION_API_TIMEOUT_SEC", 60)) * time.Second // 1リクエストあたりのタイムアウト
+	apiMaxRetries     = getEnvInt("TRANSLATION_API_MAX_RETRIES", 4)                               // 429/5xx時の最大リトライ回数
+	apiBaseBackoff    = 1 * time.Second                                                           // リトライ間隔の初期値
+	apiMaxBackoff     = 60 * time.Second                                                          // リトライ間隔の上限
+)
+
+// 翻訳APIで共有するHTTPクライアント（接続を再利用）
+var apiHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// クライアント側のレート制限（1分あたりのリクエスト数）
+var apiRateLimiter = newRateLimiter(getEnvInt("TRANSLATION_API_REQUESTS_PER_MINUTE", 15))
+
+func newRateLimiter(perMinute int) *rate.Limiter {
+	if perMinute <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	return rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), 1)
+}
+
+// 翻訳APIのエラー応答を表す構造体
+type APIError struct {
+	StatusCode int           // HTTPステータスコード
+	Status     string        // APIが返したステータス（例: RESOURCE_EXHAUSTED）
+	Message    string        // APIが返したエラーメッセージ
+	Body       string        // 応答本文（メッセージが取れない場合の確認用）
+	RetryAfter time.Duration // Retry-Afterヘッダーの値
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("API エラー (HTTP %d %s): %s", e.StatusCode, e.Status, e.Message)
+	}
+	return fmt.Sprintf("API エラー (HTTP %d): %s", e.StatusCode, e.Body)
+}
+
+// リトライ対象のステータスか判定
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// 応答からAPIErrorを作成（Google API形式のエラー本文を解析）
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	var parsed struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		apiErr.Message = parsed.Error.Message
+		apiErr.Status = parsed.Error.Status
+	}
+	return apiErr
+}
+
+// Retry-Afterヘッダー（秒数またはHTTP日付）を解析
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// 指数バックオフ（ジッター付き）の待ち時間を計算
+func backoffDelay(attempt int) time.Duration {
+	d := apiBaseBackoff << attempt
+	if d > apiMaxBackoff || d <= 0 {
+		d = apiMaxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// JSONをPOSTし、リトライ・タイムアウト・レート制限を適用して応答本文を返す関数
+func postJSONWithRetry(ctx context.Context, url string, headers map[string]string, payload interface{}) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("リクエスト作成エラー: %v", err)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= apiMaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := backoffDelay(attempt - 1)
+			if apiErr, ok := lastErr.(*APIError); ok && apiErr.RetryAfter > delay {
+				delay = apiErr.RetryAfter
+			}
+			log.Printf("APIリトライ待機: %v（%d/%d回目, 前回エラー: %v）", delay, attempt, apiMaxRetries, lastErr)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		if err := apiRateLimiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		respBody, err := postJSONOnce(ctx, url, headers, body)
+		if err == nil {
+			return respBody, nil
+		}
+		lastErr = err
+
+		// 4xx（429以外）はリトライしても結果が変わらないため即座に返す
+		if apiErr, ok := err.(*APIError); ok && !apiErr.Retryable() {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	return nil, fmt.Errorf("リトライ上限（%d回）に達しました: %w", apiMaxRetries, lastErr)
+}
+
+// 1回分のリクエストをタイムアウト付きで実行
+func postJSONOnce(ctx context.Context, url string, headers map[string]string, body []byte) ([]byte, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, apiRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("リクエスト作成エラー: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := apiHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("応答読み込みエラー: %v", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp, respBody)
+	}
+	return respBody, nil
+}
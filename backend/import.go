@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// インポートする字幕ファイルのサイズ上限（10MB）
+const maxSubtitleFileSize = 10 << 20
+
+// POST /videos/import - 既存の字幕ファイル（SRT/WebVTT）を字幕として取り込み
+// multipart/form-data: file（必須）, youtube_url, language（デフォルト: en）
+func importSubtitles(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "字幕ファイル（file）を指定してください"})
+		return
+	}
+	if fileHeader.Size > maxSubtitleFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "字幕ファイルが大きすぎます（10MB制限）"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSubtitleFileSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	segments, format, err := parseSubtitleFile(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	video := Video{
+		ID:         uuid.New().String(),
+		YoutubeUrl: c.PostForm("youtube_url"),
+		Status:     "processing",
+		CreatedAt:  time.Now().Format(time.RFC3339),
+		UpdateAt:   time.Now().Format(time.RFC3339),
+	}
+
+	t := Transcript{
+		ID:           uuid.New().String(),
+		VideoId:      video.ID,
+		Language:     c.DefaultPostForm("language", "en"),
+		TransriptSrt: joinSegmentTexts(segments, " "),
+		Segments:     segments,
+		Source:       sourceImport,
+		CreatedAt:    time.Now().Format(time.RFC3339),
+	}
+
+	mu.Lock()
+	videos = append(videos, video)
+	mu.Unlock()
+
+	log.Printf("字幕インポート: VideoID=%s, 形式=%s, セグメント数=%d", video.ID, format, len(segments))
+
+	// 音声認識は行わず、翻訳から開始
+	goJob(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("パニック回復: VideoID=%s, エラー=%v", video.ID, r)
+				failVideo(video.ID, "internal", fmt.Sprintf("内部エラー: %v", r))
+			}
+		}()
+		translateAndSave(video, t, os.Getenv("GEMINI_API_KEY"))
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"video":         video,
+		"format":        format,
+		"segment_count": len(segments),
+	})
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	speech "cloud.google.com/go/speech/apiv1"
+	"cloud.google.com/go/speech/apiv1/speechpb"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// ライブ字幕の設定（環境変数で上書き可能）
+var (
+	liveStreamRestart  = time.Duration(getEnvInt("LIVE_STREAM_RESTART_SEC", 280)) * time.Second      // StreamingRecognizeの上限（約5分）前に張り直す間隔（音声の長さ）
+	liveMaxFrameBytes  = int64(getEnvInt("LIVE_MAX_FRAME_BYTES", 1024*1024))                         // 1フレームの最大サイズ
+	liveTranslateQueue = getEnvInt("LIVE_TRANSLATE_QUEUE", 64)                                       // 翻訳待ちにできる確定セグメント数
+	liveAllowedOrigins = strings.Split(getEnv("LIVE_ALLOWED_ORIGINS", "http://localhost:5173"), ",") // WebSocket接続を許可するOrigin
+)
+
+// 受け付ける音声形式と1秒あたりのバイト数（0は圧縮形式のため経過時間で計上）
+var liveEncodings = map[string]struct {
+	encoding       speechpb.RecognitionConfig_AudioEncoding
+	bytesPerSample int
+}{
+	"LINEAR16":  {speechpb.RecognitionConfig_LINEAR16, 2},
+	"MULAW":     {speechpb.RecognitionConfig_MULAW, 1},
+	"OGG_OPUS":  {speechpb.RecognitionConfig_OGG_OPUS, 0},
+	"WEBM_OPUS": {speechpb.RecognitionConfig_WEBM_OPUS, 0},
+}
+
+var liveUpgrader = websocket.Upgrader{
+	ReadBufferSize:  32 * 1024,
+	WriteBufferSize: 32 * 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range liveAllowedOrigins {
+			if strings.TrimSpace(allowed) == origin {
+				return true
+			}
+		}
+		return false
+	},
+}
+
+// サーバーから送るメッセージ
+type liveMessage struct {
+	Type             string           `json:"type"`                        // ready, interim, final, translation, translation_skipped, error, end
+	Index            int              `json:"index"`                       // 確定セグメントの番号（interimは次に確定する番号）
+	Segment          *SubtitleSegment `json:"segment,omitempty"`           // 認識結果（時刻はセッション開始からの秒数）
+	Translation      string           `json:"translation,omitempty"`       // 確定セグメントの翻訳
+	StreamedSeconds  float64          `json:"streamed_seconds"`            // 受信した音声の長さ
+	RemainingMinutes int              `json:"remaining_minutes,omitempty"` // 今月の残り時間
+	Error            string           `json:"error,omitempty"`
+}
+
+// クライアントから送るテキストメッセージ（音声はバイナリフレームで送る）
+type liveControl struct {
+	Type string `json:"type"` // stop
+}
+
+// ライブ字幕の1セッション
+type liveSession struct {
+	conn     *websocket.Conn
+	writeMu  sync.Mutex
+	config   *speechpb.StreamingRecognitionConfig
+	bytesPer float64 // 1秒あたりのバイト数（0は経過時間で計上）
+	apiKey   string
+	language string // 認識する言語（翻訳元）
+
+	started      time.Time
+	secsMu       sync.Mutex
+	streamedSecs float64 // 受信した音声の長さ（secsMuで保護）
+	chargedSecs  float64 // 使用量として計上済みの秒数
+	finals       int     // 確定したセグメント数（受信側のみが更新）
+	lastFinalEnd float64 // 最後に確定したセグメントの終了時刻
+	translate    chan liveTranslation
+}
+
+// 翻訳待ちの確定セグメント
+type liveTranslation struct {
+	Index   int
+	Segment SubtitleSegment
+}
+
+// GET /live - WebSocketで音声を受け取り、途中結果・確定結果と翻訳を返す
+// クエリ: encoding（LINEAR16, MULAW, OGG_OPUS, WEBM_OPUS）, sample_rate, language, translate
+func liveTranscribe(c *gin.Context) {
+	encodingName := strings.ToUpper(c.DefaultQuery("encoding", "LINEAR16"))
+	enc, ok := liveEncodings[encodingName]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("未対応の音声形式です: %s", encodingName)})
+		return
+	}
+	sampleRate, err := strconv.Atoi(c.DefaultQuery("sample_rate", "16000"))
+	if err != nil || sampleRate <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sample_rateが不正です"})
+		return
+	}
+	language := c.DefaultQuery("language", speechLanguage)
+	translate := c.DefaultQuery("translate", "true") == "true"
+
+	client, err := speechClient()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if remainingSpeechMinutes() <= 0 {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Google Speech-to-Textの今月の残り時間がありません", "reason": "speech_quota_exceeded"})
+		return
+	}
+
+	conn, err := liveUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgradeがエラー応答を返しているためログのみ
+		log.Printf("WebSocket接続エラー: %v", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(liveMaxFrameBytes)
+
+	s := &liveSession{
+		conn: conn,
+		config: &speechpb.StreamingRecognitionConfig{
+			Config: &speechpb.RecognitionConfig{
+				Encoding:                   enc.encoding,
+				SampleRateHertz:            int32(sampleRate),
+				LanguageCode:               language,
+				EnableWordTimeOffsets:      true,
+				EnableAutomaticPunctuation: true,
+			},
+			InterimResults: true,
+		},
+		bytesPer: float64(enc.bytesPerSample * sampleRate),
+		apiKey:   os.Getenv("GEMINI_API_KEY"),
+		language: language,
+		started:  time.Now(),
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	var translated sync.WaitGroup
+	if translate {
+		s.translate = make(chan liveTranslation, liveTranslateQueue)
+		translated.Add(1)
+		go func() {
+			defer translated.Done()
+			s.translateLoop(ctx)
+		}()
+	}
+
+	log.Printf("ライブ字幕開始: 形式=%s, サンプルレート=%d, 言語=%s", encodingName, sampleRate, language)
+	s.send(liveMessage{Type: "ready", RemainingMinutes: remainingSpeechMinutes()})
+	audio := make(chan []byte, 16)
+	go s.readLoop(ctx, audio)
+
+	if err := s.recognizeLoop(ctx, client, audio); err != nil {
+		log.Printf("ライブ字幕エラー: %v", err)
+		s.send(liveMessage{Type: "error", Error: err.Error()})
+	}
+
+	// 翻訳待ちの確定セグメントを翻訳し終えてから終了
+	if s.translate != nil {
+		close(s.translate)
+		translated.Wait()
+	}
+	s.send(liveMessage{Type: "end", Index: s.finals, StreamedSeconds: s.seconds(), RemainingMinutes: remainingSpeechMinutes()})
+	log.Printf("ライブ字幕終了: 音声%.1f秒, 確定セグメント%d件, 使用時間%.1f秒", s.seconds(), s.finals, s.chargedSecs)
+}
+
+// クライアントからの音声を受け取る（stopメッセージまたは切断で終了）
+func (s *liveSession) readLoop(ctx context.Context, audio chan<- []byte) {
+	defer close(audio)
+	for {
+		msgType, data, err := s.conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("WebSocket読み込みエラー: %v", err)
+			}
+			return
+		}
+
+		if msgType == websocket.TextMessage {
+			var ctrl liveControl
+			if err := json.Unmarshal(data, &ctrl); err == nil && ctrl.Type == "stop" {
+				return
+			}
+			continue
+		}
+
+		select {
+		case audio <- data:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// 音声をStreamingRecognizeに送り、結果を返す
+// 1つのストリームで送れる音声の長さには上限があるため、一定の長さごとに張り直す
+func (s *liveSession) recognizeLoop(ctx context.Context, client *speech.Client, audio <-chan []byte) error {
+	for {
+		stream, err := client.StreamingRecognize(ctx)
+		if err != nil {
+			return fmt.Errorf("音声認識開始エラー: %v", err)
+		}
+		if err := stream.Send(&speechpb.StreamingRecognizeRequest{
+			StreamingRequest: &speechpb.StreamingRecognizeRequest_StreamingConfig{StreamingConfig: s.config},
+		}); err != nil {
+			return fmt.Errorf("音声認識設定エラー: %v", err)
+		}
+
+		// ストリーム内の時刻はストリーム開始からの秒数のため、開始位置を足して戻す
+		offset := s.seconds()
+		received := make(chan error, 1)
+		receiveDone := make(chan struct{})
+		go func() {
+			received <- s.receive(stream, offset)
+			close(receiveDone)
+		}()
+
+		finished, sendErr := s.sendAudio(ctx, stream, audio, receiveDone, offset)
+		stream.CloseSend()
+		if err := <-received; err != nil {
+			return err
+		}
+		if sendErr != nil {
+			return sendErr
+		}
+		if finished {
+			return nil
+		}
+	}
+}
+
+// 音声を送る（音声が終わった場合はtrue、ストリームを張り直す場合はfalse）
+// 受信側が先に終わった場合（認識エラーなど）は次の音声を待たずに戻る
+func (s *liveSession) sendAudio(ctx context.Context, stream speechpb.Speech_StreamingRecognizeClient, audio <-chan []byte, receiveDone <-chan struct{}, offset float64) (bool, error) {
+	for {
+		var data []byte
+		select {
+		case d, ok := <-audio:
+			if !ok {
+				return true, nil
+			}
+			data = d
+		case <-receiveDone:
+			// 受信側のエラーはrecognizeLoopで返す（エラーでなければ張り直す）
+			return false, nil
+		case <-ctx.Done():
+			return true, nil
+		}
+
+		if err := s.account(len(data)); err != nil {
+			return true, err
+		}
+		if err := stream.Send(&speechpb.StreamingRecognizeRequest{
+			StreamingRequest: &speechpb.StreamingRecognizeRequest_AudioContent{AudioContent: data},
+		}); err != nil {
+			if err == io.EOF {
+				// ストリーム側で終了した場合は受信側のエラーを返す
+				return false, nil
+			}
+			return true, fmt.Errorf("音声送信エラー: %v", err)
+		}
+		if s.seconds()-offset >= liveStreamRestart.Seconds() {
+			return false, nil
+		}
+	}
+}
+
+// 受信した音声の長さを加算し、増えた秒数を使用量に計上（残り時間がなくなったらエラー）
+func (s *liveSession) account(n int) error {
+	s.secsMu.Lock()
+	if s.bytesPer > 0 {
+		s.streamedSecs += float64(n) / s.bytesPer
+	} else {
+		s.streamedSecs = time.Since(s.started).Seconds()
+	}
+	secs := s.streamedSecs
+	s.secsMu.Unlock()
+
+	if secs > s.chargedSecs {
+		if !reserveSpeechSeconds(secs - s.chargedSecs) {
+			return fmt.Errorf("Google Speech-to-Textの今月の残り時間がなくなりました（%.0f秒で停止）", secs)
+		}
+		s.chargedSecs = secs
+	}
+	return nil
+}
+
+// 認識結果を受け取ってクライアントに送る
+func (s *liveSession) receive(stream speechpb.Speech_StreamingRecognizeClient, offset float64) error {
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("音声認識エラー: %v", err)
+		}
+		if resp.Error != nil {
+			return fmt.Errorf("音声認識エラー: %s", resp.Error.Message)
+		}
+
+		for _, result := range resp.Results {
+			if len(result.Alternatives) == 0 {
+				continue
+			}
+			alt := result.Alternatives[0]
+			seg := SubtitleSegment{
+				StartTime: s.lastFinalEnd,
+				EndTime:   offset + result.ResultEndTime.AsDuration().Seconds(),
+				Text:      strings.TrimSpace(alt.Transcript),
+			}
+			if result.IsFinal {
+				// 信頼度は確定結果にのみ付く
+				seg.Confidence = float64(alt.Confidence)
+			}
+			if len(alt.Words) > 0 {
+				seg.StartTime = offset + alt.Words[0].StartTime.AsDuration().Seconds()
+			}
+			if seg.Text == "" {
+				continue
+			}
+
+			if !result.IsFinal {
+				s.send(liveMessage{Type: "interim", Index: s.finals, Segment: &seg, StreamedSeconds: s.seconds()})
+				continue
+			}
+
+			index := s.finals
+			s.finals++
+			s.lastFinalEnd = seg.EndTime
+			s.send(liveMessage{Type: "final", Index: index, Segment: &seg, StreamedSeconds: s.seconds()})
+			if s.translate != nil {
+				select {
+				case s.translate <- liveTranslation{Index: index, Segment: seg}:
+				default:
+					log.Printf("翻訳待ちがいっぱいのためスキップ: Index=%d", index)
+					s.send(liveMessage{Type: "translation_skipped", Index: index, Segment: &seg, Error: "翻訳待ちがいっぱいのため翻訳をスキップしました"})
+				}
+			}
+		}
+	}
+}
+
+// 確定セグメントを順番に翻訳してクライアントに送る
+// 翻訳中に溜まった確定セグメントは次の1回のリクエストにまとめる（レート制限で待ちが詰まらないように）
+func (s *liveSession) translateLoop(ctx context.Context) {
+	for item := range s.translate {
+		batch := []liveTranslation{item}
+	drain:
+		for {
+			select {
+			case next, ok := <-s.translate:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+
+		segments := make([]SubtitleSegment, len(batch))
+		for i, b := range batch {
+			segments[i] = b.Segment
+		}
+		translated, err := translateSegmentsWithGPT(ctx, segments, s.language, translationTargetLang, s.apiKey)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("ライブ翻訳エラー: %v", err)
+				for _, b := range batch {
+					s.send(liveMessage{Type: "error", Index: b.Index, Error: fmt.Sprintf("翻訳エラー: %v", err)})
+				}
+			}
+			continue
+		}
+		for i, b := range batch {
+			if i < len(translated) {
+				s.send(liveMessage{Type: "translation", Index: b.Index, Segment: &b.Segment, Translation: translated[i].Text})
+			}
+		}
+	}
+}
+
+// 受信した音声の長さ（秒）
+func (s *liveSession) seconds() float64 {
+	s.secsMu.Lock()
+	defer s.secsMu.Unlock()
+	return s.streamedSecs
+}
+
+// メッセージを送る（送信側が複数あるためロックする）
+func (s *liveSession) send(msg liveMessage) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if err := s.conn.WriteJSON(msg); err != nil {
+		log.Printf("WebSocket書き込みエラー: %v", err)
+	}
+}
@@ -37,37 +37,49 @@ func main() {
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
+	"math"
 	"net/http"
 	"os"
-	"os/exec"
-	"strings"
+	"os/signal"
+	"path/filepath"
 	"sync"
+	"syscall"
 	"time"
 
-	speech "cloud.google.com/go/speech/apiv1"
-	"cloud.google.com/go/speech/apiv1/speechpb"
-	"cloud.google.com/go/storage"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
-	"google.golang.org/api/option"
 )
 
 // 動画の情報を表す構造体
 type Video struct {
-	ID         string `json:"id"`
-	YoutubeUrl string `json:"youtube_url"`
-	AudioPath  string `json:"audio_url"`
-	Status     string `json:"status"`
-	CreatedAt  string `json:"created_at"`
-	UpdateAt   string `json:"update_at"`
+	ID          string            `json:"id"`
+	YoutubeUrl  string            `json:"youtube_url"`
+	AudioPath   string            `json:"audio_url"`
+	Status      string            `json:"status"`
+	Progress    float64           `json:"progress"`               // ダウンロードの進捗（0〜100）
+	Error       string            `json:"error,omitempty"`        // 失敗時のエラーメッセージ
+	ErrorReason string            `json:"error_reason,omitempty"` // 失敗理由のコード（sign_in_requiredなど）
+	Options     VideoOptions      `json:"options"`
+	Metadata    *VideoMetadata    `json:"metadata,omitempty"`  // yt-dlpから取得したメタデータ
+	BatchID     string            `json:"batch_id,omitempty"`  // 一括登録された場合のバッチID
+	Artifacts   map[string]string `json:"artifacts,omitempty"` // 保管先に保存したファイル名とURI
+	CreatedAt   string            `json:"created_at"`
+	UpdateAt    string            `json:"update_at"`
+}
+
+// 動画ごとの処理オプション
+type VideoOptions struct {
+	UseCaptions bool          `json:"use_captions"`           // 音声認識の前にYouTubeの字幕取得を試す
+	ClipStart   float64       `json:"start_time,omitempty"`   // 切り出し開始位置（秒）
+	ClipEnd     float64       `json:"end_time,omitempty"`     // 切り出し終了位置（秒、0は動画の終わりまで）
+	Ytdlp       *YtdlpOptions `json:"ytdlp,omitempty"`        // yt-dlpオプションのジョブごとの上書き
+	SpeechAPI   string        `json:"speech_api,omitempty"`   // Speech-to-TextのAPI（v1, v2、空ならSPEECH_API_VERSION）
+	SpeechModel string        `json:"speech_model,omitempty"` // 認識モデル（空ならSPEECH_MODELかAPIごとの既定）
+	UseEnhanced *bool         `json:"use_enhanced,omitempty"` // 拡張モデルを使うか（v1のみ、空ならSPEECH_USE_ENHANCED）
 }
 
 // Google Speech-to-Text使用量追跡構造体
@@ -78,19 +90,40 @@ type SpeechUsage struct {
 
 // 字幕セグメント構造体（SRT生成用）
 type SubtitleSegment struct {
-	StartTime float64 `json:"start_time"` // 秒単位
-	EndTime   float64 `json:"end_time"`   // 秒単位
-	Text      string  `json:"text"`
+	StartTime    float64              `json:"start_time"` // 秒単位
+	EndTime      float64              `json:"end_time"`   // 秒単位
+	Text         string               `json:"text"`
+	Confidence   float64              `json:"confidence,omitempty"`   // 音声認識の信頼度（0〜1、0は不明）
+	Words        []WordInfo           `json:"words,omitempty"`        // 単語ごとのタイムスタンプと信頼度（音声認識のみ）
+	Alternatives []SegmentAlternative `json:"alternatives,omitempty"` // 音声認識の他の候補（PATCHのaction=chooseで本文と入れ替え可能）
+}
+
+// 音声認識の候補構造体
+type SegmentAlternative struct {
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence,omitempty"` // 0〜1、0は不明
+}
+
+// 単語情報構造体
+type WordInfo struct {
+	Word       string  `json:"word"`
+	StartTime  float64 `json:"start_time"`           // 秒単位
+	EndTime    float64 `json:"end_time"`             // 秒単位
+	Confidence float64 `json:"confidence,omitempty"` // 0〜1、0は不明
 }
 
 // 字幕（文字起こし）の情報を表す構造体
 type Transcript struct {
-	ID           string             `json:"id"`
-	VideoId      string             `json:"video_id"`
-	Language     string             `json:"language"`
-	TransriptSrt string             `json:"transcript_srt"` // 全文テキスト（後方互換性のため）
-	Segments     []SubtitleSegment  `json:"segments"`       // SRT生成用セグメント
-	CreatedAt    string             `json:"created_at"`
+	ID           string            `json:"id"`
+	VideoId      string            `json:"video_id"`
+	Language     string            `json:"language"`
+	TransriptSrt string            `json:"transcript_srt"` // 全文テキスト（後方互換性のため）
+	Segments     []SubtitleSegment `json:"segments"`       // SRT生成用セグメント
+	Source       string            `json:"source"`                 // 取得元（speech, youtube_captions, youtube_auto_captions, import）
+	SpeechAPI    string            `json:"speech_api,omitempty"`   // 音声認識に使ったAPI（v1, v2）
+	SpeechModel  string            `json:"speech_model,omitempty"` // 音声認識に使ったモデル
+	UseEnhanced  bool              `json:"use_enhanced,omitempty"` // 拡張モデルを使ったか
+	CreatedAt    string            `json:"created_at"`
 }
 
 // 翻訳済み字幕情報を表す構造体
@@ -99,17 +132,10 @@ type Translation struct {
 	TranscriptId  string `json:"transcript_id"`
 	SourceLang    string `json:"source_lang"`
 	TargetLang    string `json:"target_lang"`
-	TranslatedSrt string `json:"translated_srt"`
-	ModelUsed     string `json:"model_used"`
-	CreatedAt     string `json:"created_at"`
-}
-
-// 翻訳結果構造体
-type TranslationResult struct {
-	TranslatedText string `json:"translated_text"`
-	Language       string `json:"language"`
-	Timestamp      string `json:"timestamp"`
-	Status         string `json:"status"`
+	TranslatedSrt string            `json:"translated_srt"`
+	Segments      []SubtitleSegment `json:"segments"` // 翻訳済みセグメント（元セグメントと同じタイミング）
+	ModelUsed     string            `json:"model_used"`
+	CreatedAt     string            `json:"created_at"`
 }
 
 // スライス（配列）（DBのテーブル代わりメモリ上に置くためサーバー停止後消える）
@@ -130,7 +156,7 @@ var (
 
 // Google Speech-to-Text使用時間管理（月間60分制限）
 var (
-	speechUsageMinutes int
+	speechUsageSeconds float64 // 秒単位で計上し、分数は必要な時に換算
 	speechUsageStart   = time.Now()
 	speechLimit        = 60 // 月60分
 	muSpeech           sync.Mutex
@@ -161,11 +187,22 @@ func canUseSpeechToText(audioDurationMinutes int) bool {
 
 	// 月が変わったらリセット（30日基準）
 	if time.Since(speechUsageStart).Hours() > 24*30 {
-		speechUsageMinutes = 0
+		speechUsageSeconds = 0
 		speechUsageStart = time.Now()
 	}
 
-	return speechUsageMinutes+audioDurationMinutes <= speechLimit
+	return speechUsageSeconds+float64(audioDurationMinutes*60) <= float64(speechLimit*60)
+}
+
+// Google Speech-to-Textの今月の残り分数（1分未満の端数は切り捨て）
+func remainingSpeechMinutes() int {
+	muSpeech.Lock()
+	defer muSpeech.Unlock()
+
+	if time.Since(speechUsageStart).Hours() > 24*30 {
+		return speechLimit
+	}
+	return int(math.Floor((float64(speechLimit*60) - speechUsageSeconds) / 60))
 }
 
 // Google Speech-to-Text使用量を更新
@@ -173,36 +210,110 @@ func updateSpeechUsage(audioDurationMinutes int) {
 	muSpeech.Lock()
 	defer muSpeech.Unlock()
 
-	speechUsageMinutes += audioDurationMinutes
+	speechUsageSeconds += float64(audioDurationMinutes * 60)
+}
+
+// 残り時間があれば使用量を秒単位で計上（確認と計上を同時に行う）
+func reserveSpeechSeconds(seconds float64) bool {
+	muSpeech.Lock()
+	defer muSpeech.Unlock()
+
+	if time.Since(speechUsageStart).Hours() > 24*30 {
+		speechUsageSeconds = 0
+		speechUsageStart = time.Now()
+	}
+	if speechUsageSeconds+seconds > float64(speechLimit*60) {
+		return false
+	}
+	speechUsageSeconds += seconds
+	return true
 }
 func main() {
-	// 環境変数を読み込み
-	err := godotenv.Load()
-	if err != nil {
-		log.Println("Warning: .env file not found")
+	// 環境変数は設定値の初期化時に読み込み済み（config.go）
+
+	// Google Cloudのクライアントを作成（失敗しても字幕インポートなどは使えるため起動は続ける）
+	if err := initGoogleClients(context.Background()); err != nil {
+		log.Printf("Warning: Google Cloudクライアントを作成できません: %v", err)
+	}
+	if err := initBlobStores(); err != nil {
+		log.Printf("Warning: ストレージを作成できません: %v", err)
 	}
 
+	// SIGINT/SIGTERMでリクエスト・処理中のジョブを待ってから終了
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 作業ディレクトリの定期掃除を開始
+	startJanitor(ctx)
+	// 翻訳キャッシュの定期書き出しを開始
+	startTranslationCacheFlusher(ctx)
+
 	// Ginルーターを設定
 	router := gin.Default()
 
 	// CORSの設定
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"}, // Reactのアドレス
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Author"},
 		AllowCredentials: true,
 	}))
 
 	// ルートを設定
 	router.GET("/videos", getVideos)
 	router.POST("/videos", createVideo)
+	router.POST("/videos/import", importSubtitles)
+	router.GET("/live", liveTranscribe)
+	router.GET("/batches", getBatches)
+	router.POST("/batches", createBatch)
+	router.GET("/batches/:id", getBatch)
+	router.GET("/batches/:id/download", downloadBatch)
 	router.GET("/videos/:id", getVideo)
 	router.PUT("/videos/:id/status", updateVideoStatusHandler)
 	router.GET("/videos/:id/transcript", getTranscript)
+	router.GET("/videos/:id/transcript/review", reviewTranscript)
+	router.GET("/videos/:id/transcript/qa", timingReport(docTranscript))
+	router.POST("/videos/:id/transcript/qa/fix", fixTimingHandler(docTranscript))
+	router.GET("/videos/:id/translation/qa", timingReport(docTranslation))
+	router.POST("/videos/:id/translation/qa/fix", fixTimingHandler(docTranslation))
+	router.GET("/videos/:id/transcript/export", exportDocument(docTranscript))
+	router.GET("/videos/:id/translation/export", exportDocument(docTranslation))
+	router.POST("/videos/:id/transcript/retime", retimeDocument(docTranscript))
+	router.POST("/videos/:id/translation/retime", retimeDocument(docTranslation))
 	router.GET("/videos/:id/translation", getTranslation)
+	router.PATCH("/videos/:id/transcript/segments/:n", patchTranscriptSegment)
+	router.PATCH("/videos/:id/translation/segments/:n", patchTranslationSegment)
+	router.GET("/videos/:id/transcript/revisions", listRevisions(docTranscript))
+	router.GET("/videos/:id/transcript/revisions/diff", diffRevisions(docTranscript))
+	router.POST("/videos/:id/transcript/revisions/:rev/restore", restoreRevision(docTranscript))
+	router.GET("/videos/:id/translation/revisions", listRevisions(docTranslation))
+	router.GET("/videos/:id/translation/revisions/diff", diffRevisions(docTranslation))
+	router.POST("/videos/:id/translation/revisions/:rev/restore", restoreRevision(docTranslation))
+
+	srv := &http.Server{Addr: ":8080", Handler: router}
+	go func() {
+		log.Println("Server started at :8080")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("サーバー起動エラー: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("シャットダウン開始")
 
-	log.Println("Server started at :8080")
-	router.Run(":8080")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("サーバー終了エラー: %v", err)
+	}
+	if !waitJobs(shutdownTimeout) {
+		log.Println("処理中のジョブが残っていますが終了します")
+	}
+	if err := segmentCache.Flush(); err != nil {
+		log.Printf("翻訳キャッシュ保存エラー: %v", err)
+	}
+	closeGoogleClients()
+	log.Println("シャットダウン完了")
 }
 
 // GET /videos - 全動画取得
@@ -215,7 +326,14 @@ func getVideos(c *gin.Context) {
 // POST /videos - 新規動画作成
 func createVideo(c *gin.Context) {
 	var req struct {
-		YoutubeURL string `json:"youtube_url" binding:"required"`
+		YoutubeURL  string        `json:"youtube_url" binding:"required"`
+		UseCaptions *bool         `json:"use_captions"` // 省略時はYOUTUBE_CAPTIONS_ENABLEDに従う
+		StartTime   *float64      `json:"start_time"`   // 切り出し開始位置（秒）
+		EndTime     *float64      `json:"end_time"`     // 切り出し終了位置（秒）
+		Ytdlp       *YtdlpOptions `json:"ytdlp"`        // yt-dlpオプションの上書き（proxy, cookies_file, formatなど）
+		SpeechAPI   string        `json:"speech_api"`   // Speech-to-TextのAPI（v1, v2）
+		SpeechModel string        `json:"speech_model"` // 認識モデル（latest_long, chirp, telephonyなど）
+		UseEnhanced *bool         `json:"use_enhanced"` // 拡張モデルを使うか（v1のみ）
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -228,16 +346,53 @@ func createVideo(c *gin.Context) {
 		ID:         uuid.New().String(),
 		YoutubeUrl: req.YoutubeURL,
 		Status:     "processing",
-		CreatedAt:  time.Now().Format(time.RFC3339),
-		UpdateAt:   time.Now().Format(time.RFC3339),
+		Options: VideoOptions{
+			UseCaptions: preferYoutubeCaptions,
+		},
+		CreatedAt: time.Now().Format(time.RFC3339),
+		UpdateAt:  time.Now().Format(time.RFC3339),
+	}
+	if req.UseCaptions != nil {
+		video.Options.UseCaptions = *req.UseCaptions
+	}
+	if req.StartTime != nil {
+		video.Options.ClipStart = *req.StartTime
+	}
+	if req.EndTime != nil {
+		video.Options.ClipEnd = *req.EndTime
+	}
+	if err := req.Ytdlp.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "reason": "invalid_ytdlp_options"})
+		return
+	}
+	video.Options.Ytdlp = req.Ytdlp
+	video.Options.SpeechAPI = req.SpeechAPI
+	video.Options.SpeechModel = req.SpeechModel
+	video.Options.UseEnhanced = req.UseEnhanced
+	if err := video.Options.speech().validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "reason": "invalid_speech_options"})
+		return
+	}
+	if err := video.Options.validateClip(0); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "reason": "invalid_clip"})
+		return
 	}
 
+	// 事前チェック（メタデータ取得・処理できない動画の拒否）
+	info, perr := preflightVideo(c.Request.Context(), req.YoutubeURL, video.Options)
+	if perr != nil {
+		log.Printf("事前チェックで拒否: URL=%s, 理由=%s", req.YoutubeURL, perr.Reason)
+		c.JSON(perr.Status, gin.H{"error": perr.Message, "reason": perr.Reason})
+		return
+	}
+	video.Metadata = info.metadata()
+
 	mu.Lock()
 	videos = append(videos, video)
 	mu.Unlock()
 
 	// バックグラウンド処理を開始
-	go processVideo(video, os.Getenv("GEMINI_API_KEY"))
+	goJob(func() { processVideo(video, os.Getenv("GEMINI_API_KEY")) })
 
 	c.JSON(http.StatusCreated, video)
 }
@@ -300,11 +455,9 @@ func getTranslation(c *gin.Context) {
 	mu.Lock()
 	defer mu.Unlock()
 
-	for _, translation := range translations {
-		if translation.TranscriptId == id {
-			c.JSON(http.StatusOK, translation)
-			return
-		}
+	if i := findTranslationIndexByVideo(id); i >= 0 {
+		c.JSON(http.StatusOK, translations[i])
+		return
 	}
 
 	c.JSON(http.StatusNotFound, gin.H{"error": "Translation not found"})
@@ -324,33 +477,146 @@ func updateVideoStatus(videoID, status string) {
 	}
 }
 
+// 動画を失敗状態にして理由コードとエラーメッセージを記録
+func failVideo(videoID, reason, message string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i, video := range videos {
+		if video.ID == videoID {
+			videos[i].Status = "error"
+			videos[i].Error = message
+			videos[i].ErrorReason = reason
+			videos[i].UpdateAt = time.Now().Format(time.RFC3339)
+			break
+		}
+	}
+}
+
+// 動画のメタデータを更新
+func updateVideoMetadata(videoID string, metadata *VideoMetadata) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i, video := range videos {
+		if video.ID == videoID {
+			videos[i].Metadata = metadata
+			break
+		}
+	}
+}
+
+// 動画の進捗を更新
+func updateVideoProgress(videoID string, progress float64) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i, video := range videos {
+		if video.ID == videoID {
+			videos[i].Progress = progress
+			break
+		}
+	}
+}
+
 // バックグラウンド処理
 func processVideo(v Video, apiKey string) {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("パニック回復: VideoID=%s, エラー=%v", v.ID, r)
-			updateVideoStatus(v.ID, "error")
+			failVideo(v.ID, "internal", fmt.Sprintf("内部エラー: %v", r))
 		}
 	}()
 	
 	log.Printf("処理開始: VideoID=%s", v.ID)
-	audioFile := v.ID + ".mp3"
+	ctx := context.Background()
+
+	// ジョブ用の作業ディレクトリ（終了時に保持ポリシーに従って片付け）
+	jobDir, err := createJobDir(v.ID)
+	if err != nil {
+		failVideo(v.ID, "internal", err.Error())
+		log.Println(err)
+		return
+	}
+	defer finishJobDir(v.ID, jobDir)
+
+	// 0. YouTubeの字幕があればそれを使い、音声認識（と使用量）を省略
+	if v.Options.UseCaptions {
+		log.Printf("YouTube字幕取得開始: %s", v.YoutubeUrl)
+		segments, source, lang, err := fetchYoutubeCaptions(ctx, v, jobDir)
+		if err == nil && v.Options.HasClip() {
+			// 字幕は元動画の時刻なので、切り出し範囲内だけを使う
+			segments = clipSegments(segments, v.Options.ClipStart, v.Options.ClipEnd)
+			if len(segments) == 0 {
+				err = fmt.Errorf("切り出し範囲内に字幕がありません")
+			}
+		}
+		if err == nil {
+			log.Printf("YouTube字幕取得完了: 取得元=%s, 言語=%s, セグメント数=%d", source, lang, len(segments))
+			translateAndSave(v, Transcript{
+				ID:           uuid.New().String(),
+				VideoId:      v.ID,
+				Language:     lang,
+				TransriptSrt: joinSegmentTexts(segments, " "),
+				Segments:     segments,
+				Source:       source,
+				CreatedAt:    time.Now().Format(time.RFC3339),
+			}, apiKey)
+			return
+		}
+		log.Printf("YouTube字幕が使えないため音声認識に切り替え: %v", err)
+	}
+
+	// メタデータから長さが分かる場合はダウンロード前に使用制限をチェック
+	if minutes := v.speechMinutes(); minutes > 0 && !canUseSpeechToText(minutes) {
+		failVideo(v.ID, "speech_quota_exceeded", fmt.Sprintf("Google Speech-to-Text月間制限（60分）を超過: 動画の長さ%d分", minutes))
+		log.Printf("Google Speech-to-Text月間制限（60分）を超過: 動画の長さ%d分", minutes)
+		return
+	}
+
+	audioFile := filepath.Join(jobDir, v.ID+".mp3")
+
+	// ダウンロード前に空き容量を確認（1分≈1MBで見込む）
+	requiredMB := uint64(v.speechMinutes())
+	if requiredMB == 0 {
+		requiredMB = 100
+	}
+	if err := checkDiskSpace(requiredMB); err != nil {
+		failVideo(v.ID, "disk_space", err.Error())
+		log.Println(err)
+		return
+	}
 
 	// 1. yt-dlpで音声抽出
 	log.Printf("yt-dlp開始: %s", v.YoutubeUrl)
-	cmdYtdlp := exec.Command(
-		"yt-dlp",
+	ytdlpOpts := v.Options.ytdlp()
+	args := append(ytdlpOpts.args(),
 		"-x",
 		"--audio-format", "mp3",
 		"-o", audioFile,
-		v.YoutubeUrl,
 	)
-	if err := cmdYtdlp.Run(); err != nil {
-		updateVideoStatus(v.ID, "error")
+	if ytdlpOpts.Format != "" {
+		args = append(args, "-f", ytdlpOpts.Format)
+	}
+	if v.Options.HasClip() {
+		// 切り出し範囲だけをダウンロード
+		args = append(args, "--download-sections", v.Options.downloadSection(), "--force-keyframes-at-cuts")
+	}
+	_, err = runYtdlp(ctx, ytdlpTimeout, append(args, v.YoutubeUrl), func(pct float64) {
+		updateVideoProgress(v.ID, pct)
+	})
+	if err != nil {
+		// サインイン要求（ボット判定）などは理由コードを分けて記録
+		reason, message, ok := classifyYtdlpError(err)
+		if !ok {
+			reason, message = "download_failed", "音声のダウンロードに失敗しました"
+		}
+		failVideo(v.ID, reason, fmt.Sprintf("%s: %v", message, err))
 		log.Println("yt-dlp error:", err)
 		return
 	}
 	log.Printf("yt-dlp完了: %s", audioFile)
+	archiveAudioFile(ctx, v.ID, audioFile)
 
 	// 2. Google Speech-to-Textで文字起こし
 	log.Printf("Google Speech-to-Text開始: %s", audioFile)
@@ -358,291 +624,134 @@ func processVideo(v Video, apiKey string) {
 	// 音声時間を推定（簡易実装：ファイルサイズから推定）
 	audioInfo, err := os.Stat(audioFile)
 	if err != nil {
-		updateVideoStatus(v.ID, "error")
+		failVideo(v.ID, "internal", fmt.Sprintf("音声ファイル情報取得エラー: %v", err))
 		log.Printf("音声ファイル情報取得エラー: %v", err)
 		return
 	}
 
 	// 簡易推定：1MB ≈ 1分の音声（実際はもっと複雑）
+	// メタデータに長さがある場合はそちらを優先（切り出し時は切り出した長さ）
 	estimatedMinutes := int(audioInfo.Size() / (1024 * 1024))
+	if minutes := v.speechMinutes(); minutes > 0 {
+		estimatedMinutes = minutes
+	}
 	if estimatedMinutes < 1 {
 		estimatedMinutes = 1 // 最低1分として計算
 	}
 
 	// 使用制限チェック
 	if !canUseSpeechToText(estimatedMinutes) {
-		updateVideoStatus(v.ID, "error")
+		failVideo(v.ID, "speech_quota_exceeded", fmt.Sprintf("Google Speech-to-Text月間制限（60分）を超過: 推定%d分", estimatedMinutes))
 		log.Printf("Google Speech-to-Text月間制限（60分）を超過: 推定%d分", estimatedMinutes)
 		return
 	}
 
-	transcriptText, segments, err := transcribeWithGoogleSpeech(audioFile)
+	speechOpts := v.Options.speech()
+	transcriptText, segments, err := transcribeWithGoogleSpeech(audioFile, v.speechSeconds(), speechOpts)
 	if err != nil {
-		updateVideoStatus(v.ID, "error")
+		failVideo(v.ID, "speech_failed", fmt.Sprintf("Google Speech-to-Text error: %v", err))
 		log.Printf("Google Speech-to-Text error: %v", err)
 		return
 	}
 
+	// 切り出した音声の時刻を元動画の時刻に合わせる
+	if v.Options.ClipStart > 0 {
+		segments = shiftSegments(segments, v.Options.ClipStart)
+	}
+
 	// 使用量を更新
 	updateSpeechUsage(estimatedMinutes)
 	log.Printf("Google Speech-to-Text完了: 文字数=%d, 使用時間=%d分", len(transcriptText), estimatedMinutes)
 
-	// 3. GPT翻訳
-	log.Printf("翻訳開始: %d文字", len(transcriptText))
-	_, err = translateTextWithGPT(transcriptText, apiKey)
+	t := Transcript{
+		ID:           uuid.New().String(),
+		VideoId:      v.ID,
+		Language:     speechOpts.Language,
+		TransriptSrt: transcriptText,
+		Segments:     segments,
+		Source:       sourceSpeech,
+		SpeechAPI:    speechOpts.API,
+		SpeechModel:  speechOpts.Model,
+		UseEnhanced:  speechOpts.UseEnhanced,
+		CreatedAt:    time.Now().Format(time.RFC3339),
+	}
+
+	translateAndSave(v, t, apiKey)
+}
+
+// 字幕を翻訳して字幕・翻訳を保存（文字起こし・字幕インポート共通の後半処理）
+func translateAndSave(v Video, t Transcript, apiKey string) {
+	// 字幕のタイミングを検査・自動修正（修正前の字幕もリビジョンに残す）
+	created := t.Segments
+	fixedSegments, transcriptFixed := applyTimingQA(v.ID, docTranscript, t.Language, t.Segments)
+	t.Segments = fixedSegments
+	t.TransriptSrt = joinSegmentTexts(fixedSegments, " ")
+
+	// 3. GPT翻訳（セグメント境界でチャンク分割）
+	log.Printf("翻訳開始: %d文字", len(t.TransriptSrt))
+	translatedSegments, err := translateSegmentsWithGPT(context.Background(), t.Segments, t.Language, translationTargetLang, apiKey)
 	if err != nil {
-		updateVideoStatus(v.ID, "error")
+		failVideo(v.ID, "translation_failed", fmt.Sprintf("翻訳エラー: %v", err))
 		log.Println("translation error:", err)
 		return
 	}
 	log.Printf("翻訳完了")
 
+	// 翻訳は文字数の基準が異なるため、翻訳先の言語のルールで検査・自動修正
+	translatedCreated := translatedSegments
+	translatedSegments, translationFixed := applyTimingQA(v.ID, docTranslation, translationTargetLang, translatedSegments)
+
 	// 4. 結果保存
 	log.Printf("結果保存開始: VideoID=%s", v.ID)
-	t := Transcript{
-		ID:           uuid.New().String(),
-		VideoId:      v.ID,
-		Language:     "en",
-		TransriptSrt: transcriptText,
-		Segments:     segments,
-		CreatedAt:    time.Now().Format(time.RFC3339),
+	tr := Translation{
+		ID:            uuid.New().String(),
+		TranscriptId:  t.ID,
+		SourceLang:    t.Language,
+		TargetLang:    translationTargetLang,
+		TranslatedSrt: joinSegmentTexts(translatedSegments, "\n"),
+		Segments:      translatedSegments,
+		ModelUsed:     geminiModel,
+		CreatedAt:     time.Now().Format(time.RFC3339),
 	}
 	
-	log.Printf("セグメント数: %d", len(segments))
+	log.Printf("セグメント数: %d", len(t.Segments))
 	
 	log.Printf("ロック取得試行: VideoID=%s", v.ID)
 	mu.Lock()
 	log.Printf("ロック取得成功: VideoID=%s", v.ID)
 	
 	transcripts = append(transcripts, t)
+	translations = append(translations, tr)
+	recordRevision(docTranscript, t.ID, v.ID, "system", "created", created)
+	if transcriptFixed {
+		recordRevision(docTranscript, t.ID, v.ID, "system", "timing_fix", t.Segments)
+	}
+	recordRevision(docTranslation, tr.ID, v.ID, "system", "created", translatedCreated)
+	if translationFixed {
+		recordRevision(docTranslation, tr.ID, v.ID, "system", "timing_fix", tr.Segments)
+	}
 	log.Printf("transcript追加完了: VideoID=%s", v.ID)
 	
 	// updateVideoStatus内でもmu.Lock()するためここで一旦解放
 	mu.Unlock()
 	log.Printf("ロック解除完了: VideoID=%s", v.ID)
+
+	// 字幕・翻訳を保管先に保存（ARTIFACT_STORAGE_BACKEND設定時）
+	archiveArtifacts(context.Background(), v.ID, map[string]string{
+		"transcript." + t.Language + ".srt":     renderExportSRT(t.Segments, archiveExportOptions()),
+		"translation." + tr.TargetLang + ".srt": renderExportSRT(tr.Segments, archiveExportOptions()),
+	})
 	
 	updateVideoStatus(v.ID, "completed")
 	log.Printf("ステータス更新完了: VideoID=%s", v.ID)
 	log.Printf("保存完了: VideoID=%s", v.ID)
 }
 
-// 翻訳関数（Gemini API利用）
-func translateTextWithGPT(text, apiKey string) (*TranslationResult, error) {
-	if !canTranslate(text) {
-		return nil, fmt.Errorf("翻訳上限超えました（40万文字/月）")
-	}
-
-	payload := map[string]interface{}{
-		"contents": []map[string]interface{}{
-			{
-				"parts": []map[string]string{
-					{"text": "You are a professional translator. Translate the following text to Japanese:\n" + text},
-				},
-			},
-		},
-	}
-	body, _ := json.Marshal(payload)
-
-	req, _ := http.NewRequest("POST", "https://generativelanguage.googleapis.com/v1beta/models/gemini-1.5-flash-latest:generateContent?key="+apiKey, bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var res map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		return nil, err
-	}
-
-	// Gemini APIレスポンスの存在チェック
-	if res["candidates"] == nil {
-		return nil, fmt.Errorf("API応答にcandidatesが含まれていません: %+v", res)
-	}
-	
-	candidates, ok := res["candidates"].([]interface{})
-	if !ok || len(candidates) == 0 {
-		return nil, fmt.Errorf("candidatesが空またはnilです: %+v", res["candidates"])
-	}
-
-	firstCandidate, ok := candidates[0].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("candidates[0]が不正な形式です: %+v", candidates[0])
-	}
-
-	contentObj, ok := firstCandidate["content"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("contentが存在しないか不正な形式です: %+v", firstCandidate["content"])
-	}
-
-	parts, ok := contentObj["parts"].([]interface{})
-	if !ok || len(parts) == 0 {
-		return nil, fmt.Errorf("partsが存在しないか空です: %+v", contentObj["parts"])
-	}
-
-	firstPart, ok := parts[0].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("parts[0]が不正な形式です: %+v", parts[0])
-	}
-
-	content, ok := firstPart["text"].(string)
-	if !ok {
-		return nil, fmt.Errorf("textが存在しないか文字列ではありません: %+v", firstPart["text"])
-	}
-
-	result := &TranslationResult{
-		TranslatedText: content,
-		Language:       "en",
-		Timestamp:      time.Now().Format(time.RFC3339),
-		Status:         "completed",
-	}
-
-	return result, nil
-}
-
-// Google Cloud Storageに音声ファイルをアップロードする関数
-func uploadToGCS(audioFile, bucketName string) (string, error) {
-	ctx := context.Background()
-
-	// 認証情報から GCS クライアントを作成
-	credentialsJSON := os.Getenv("GOOGLE_CREDENTIALS_JSON")
-	if credentialsJSON == "" {
-		return "", fmt.Errorf("GOOGLE_CREDENTIALS_JSON環境変数が設定されていません")
-	}
-
-	// JSONをパースして再構築
-	var rawCredentials map[string]interface{}
-	decoder := json.NewDecoder(strings.NewReader(credentialsJSON))
-	if err := decoder.Decode(&rawCredentials); err != nil {
-		return "", fmt.Errorf("認証JSON解析エラー: %v", err)
-	}
-	
-	if privateKey, ok := rawCredentials["private_key"].(string); ok {
-		rawCredentials["private_key"] = strings.ReplaceAll(privateKey, "\\n", "\n")
-	}
-	
-	credentialsBytes, err := json.Marshal(rawCredentials)
-	if err != nil {
-		return "", fmt.Errorf("認証JSON再構築エラー: %v", err)
-	}
-
-	// Storage クライアントを作成
-	client, err := storage.NewClient(ctx, option.WithCredentialsJSON(credentialsBytes))
-	if err != nil {
-		return "", fmt.Errorf("GCSクライアント作成エラー: %v", err)
-	}
-	defer client.Close()
-
-	// ファイルを読み込み
-	file, err := os.Open(audioFile)
-	if err != nil {
-		return "", fmt.Errorf("ファイル読み込みエラー: %v", err)
-	}
-	defer file.Close()
-
-	// GCSオブジェクト名を生成
-	objectName := fmt.Sprintf("audio/%s", audioFile)
-	
-	// アップロード実行
-	obj := client.Bucket(bucketName).Object(objectName)
-	w := obj.NewWriter(ctx)
-	
-	if _, err = io.Copy(w, file); err != nil {
-		return "", fmt.Errorf("アップロードエラー: %v", err)
-	}
-	
-	if err := w.Close(); err != nil {
-		return "", fmt.Errorf("アップロード完了エラー: %v", err)
-	}
-
-	// GCS URI を返す
-	gcsURI := fmt.Sprintf("gs://%s/%s", bucketName, objectName)
-	return gcsURI, nil
-}
-
-// Google Cloud Storageからファイルを削除する関数
-func deleteFromGCS(bucketName, objectName string) error {
-	ctx := context.Background()
-
-	// 認証情報から GCS クライアントを作成
-	credentialsJSON := os.Getenv("GOOGLE_CREDENTIALS_JSON")
-	if credentialsJSON == "" {
-		return fmt.Errorf("GOOGLE_CREDENTIALS_JSON環境変数が設定されていません")
-	}
-
-	// JSONをパースして再構築
-	var rawCredentials map[string]interface{}
-	decoder := json.NewDecoder(strings.NewReader(credentialsJSON))
-	if err := decoder.Decode(&rawCredentials); err != nil {
-		return fmt.Errorf("認証JSON解析エラー: %v", err)
-	}
-	
-	if privateKey, ok := rawCredentials["private_key"].(string); ok {
-		rawCredentials["private_key"] = strings.ReplaceAll(privateKey, "\\n", "\n")
-	}
-	
-	credentialsBytes, err := json.Marshal(rawCredentials)
-	if err != nil {
-		return fmt.Errorf("認証JSON再構築エラー: %v", err)
-	}
-
-	// Storage クライアントを作成
-	client, err := storage.NewClient(ctx, option.WithCredentialsJSON(credentialsBytes))
-	if err != nil {
-		return fmt.Errorf("GCSクライアント作成エラー: %v", err)
-	}
-	defer client.Close()
-
-	// ファイル削除
-	obj := client.Bucket(bucketName).Object(objectName)
-	if err := obj.Delete(ctx); err != nil {
-		return fmt.Errorf("ファイル削除エラー: %v", err)
-	}
-
-	log.Printf("GCSファイル削除完了: gs://%s/%s", bucketName, objectName)
-	return nil
-}
-
 // Google Speech-to-Textで音声ファイルを文字起こしする関数
-func transcribeWithGoogleSpeech(audioFile string) (string, []SubtitleSegment, error) {
+// durationSecは音声の長さ（秒、不明な場合は0）
+func transcribeWithGoogleSpeech(audioFile string, durationSec float64, opts speechOptions) (string, []SubtitleSegment, error) {
 	ctx := context.Background()
 
-	// 認証情報をJSON文字列から設定
-	credentialsJSON := os.Getenv("GOOGLE_CREDENTIALS_JSON")
-	if credentialsJSON == "" {
-		return "", nil, fmt.Errorf("GOOGLE_CREDENTIALS_JSON環境変数が設定されていません")
-	}
-
-	// JSONを一度パースしてから再構築することでエスケープを処理
-	var rawCredentials map[string]interface{}
-	
-	// まず生のJSONをパース
-	decoder := json.NewDecoder(strings.NewReader(credentialsJSON))
-	if err := decoder.Decode(&rawCredentials); err != nil {
-		return "", nil, fmt.Errorf("認証JSON解析エラー: %v", err)
-	}
-	
-	// private_keyの改行エスケープを修正
-	if privateKey, ok := rawCredentials["private_key"].(string); ok {
-		rawCredentials["private_key"] = strings.ReplaceAll(privateKey, "\\n", "\n")
-	}
-	
-	// 修正したJSONを再エンコード
-	credentialsBytes, err := json.Marshal(rawCredentials)
-	if err != nil {
-		return "", nil, fmt.Errorf("認証JSON再構築エラー: %v", err)
-	}
-
-	// クライアントを作成
-	client, err := speech.NewClient(ctx, option.WithCredentialsJSON(credentialsBytes))
-	if err != nil {
-		return "", nil, fmt.Errorf("Speech-to-Textクライアント作成エラー: %v", err)
-	}
-	defer client.Close()
-
 	// ファイルサイズをチェック（無料枠保護）
 	fileInfo, err := os.Stat(audioFile)
 	if err != nil {
@@ -655,75 +764,17 @@ func transcribeWithGoogleSpeech(audioFile string) (string, []SubtitleSegment, er
 		return "", nil, fmt.Errorf("ファイルサイズが大きすぎます（%dMB > 100MB制限）", fileSizeMB)
 	}
 	
-	log.Printf("音声ファイルサイズ: %dMB", fileSizeMB)
-
-	// Google Cloud Storageにアップロード
-	bucketName := os.Getenv("GCS_BUCKET_NAME")
-	if bucketName == "" {
-		return "", nil, fmt.Errorf("GCS_BUCKET_NAME環境変数が設定されていません")
-	}
-	gcsURI, err := uploadToGCS(audioFile, bucketName)
-	if err != nil {
-		return "", nil, fmt.Errorf("GCSアップロードエラー: %v", err)
-	}
-
-	log.Printf("GCSアップロード完了: %s", gcsURI)
+	log.Printf("音声ファイルサイズ: %dMB, API=%s, モデル=%s", fileSizeMB, opts.API, opts.Model)
 
-	// 長時間音声認識リクエストを作成（GCS URI使用）
-	req := &speechpb.LongRunningRecognizeRequest{
-		Config: &speechpb.RecognitionConfig{
-			Encoding:        speechpb.RecognitionConfig_MP3, // MP3形式
-			SampleRateHertz: 44100,                          // サンプルレート
-			LanguageCode:    "en-US",                        // 言語設定
-			EnableWordTimeOffsets: true,                     // 単語レベルのタイムスタンプ
-		},
-		Audio: &speechpb.RecognitionAudio{
-			AudioSource: &speechpb.RecognitionAudio_Uri{
-				Uri: gcsURI,
-			},
-		},
-	}
-
-	// 長時間音声認識を実行
-	op, err := client.LongRunningRecognize(ctx, req)
-	if err != nil {
-		return "", nil, fmt.Errorf("音声認識開始エラー: %v", err)
-	}
-
-	// 処理完了を待機
-	resp, err := op.Wait(ctx)
-	if err != nil {
-		return "", nil, fmt.Errorf("音声認識エラー: %v", err)
-	}
-
-	// 処理完了後、GCSファイルを削除（無料枠節約のため）
-	objectName := fmt.Sprintf("audio/%s", audioFile)
-	if deleteErr := deleteFromGCS(bucketName, objectName); deleteErr != nil {
-		log.Printf("GCS削除エラー（続行）: %v", deleteErr)
-	}
-
-	// 結果をテキストとセグメントに変換
-	var transcriptText string
-	var segments []SubtitleSegment
-	
-	for _, result := range resp.Results {
-		for _, alt := range result.Alternatives {
-			transcriptText += alt.Transcript + " "
-			
-			// 単語レベルのタイムスタンプから文レベルのセグメントを作成
-			if len(alt.Words) > 0 {
-				startTime := alt.Words[0].StartTime.AsDuration().Seconds()
-				endTime := alt.Words[len(alt.Words)-1].EndTime.AsDuration().Seconds()
-				
-				segment := SubtitleSegment{
-					StartTime: startTime,
-					EndTime:   endTime,
-					Text:      strings.TrimSpace(alt.Transcript),
-				}
-				segments = append(segments, segment)
-			}
+	// 長い音声は無音で分割して並列に認識
+	if useChunkedRecognize(durationSec) {
+		segments, err := transcribeChunked(ctx, audioFile, durationSec, opts)
+		if err != nil {
+			return "", nil, err
 		}
+		return joinSegmentTexts(segments, " "), segments, nil
 	}
 
-	return transcriptText, segments, nil
+	// 短い音声は同期認識（アップロードなし）、長い音声は一時保存先を使った長時間認識
+	return recognizeFile(ctx, audioFile, durationSec, fileInfo.Size(), opts)
 }
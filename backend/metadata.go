@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// yt-dlpから取得した動画のメタデータ
+type VideoMetadata struct {
+	Title      string  `json:"title"`
+	Channel    string  `json:"channel"`
+	Duration   float64 `json:"duration"` // 秒単位
+	Thumbnail  string  `json:"thumbnail"`
+	UploadDate string  `json:"upload_date"` // YYYY-MM-DD形式
+	Language   string  `json:"language"`
+}
+
+// yt-dlp --dump-json の出力（必要な項目のみ）
+type ytdlpInfo struct {
+	Type         string  `json:"_type"`
+	Title        string  `json:"title"`
+	Channel      string  `json:"channel"`
+	Uploader     string  `json:"uploader"`
+	Duration     float64 `json:"duration"`
+	Thumbnail    string  `json:"thumbnail"`
+	UploadDate   string  `json:"upload_date"` // YYYYMMDD形式
+	Language     string  `json:"language"`
+	IsLive       bool    `json:"is_live"`
+	LiveStatus   string  `json:"live_status"`
+	Availability string  `json:"availability"`
+	AgeLimit     int     `json:"age_limit"`
+}
+
+// yt-dlpで動画のメタデータを取得（ダウンロードは行わない）
+func fetchVideoInfo(ctx context.Context, url string, opts YtdlpOptions) (*ytdlpInfo, error) {
+	// 失敗理由を判別できるようにエラーにはstderrが含まれる
+	out, err := runYtdlp(ctx, ytdlpMetadataTimeout, append(opts.args(),
+		"--dump-json",
+		"--skip-download",
+		"--no-playlist",
+		url,
+	), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// プレイリストの場合は1行1件で出力されるため最初の1件だけ読む
+	var info ytdlpInfo
+	if err := json.NewDecoder(bytes.NewReader(out)).Decode(&info); err != nil {
+		return nil, fmt.Errorf("メタデータ解析エラー: %v", err)
+	}
+	return &info, nil
+}
+
+// yt-dlpの出力をVideoMetadataに変換
+func (info *ytdlpInfo) metadata() *VideoMetadata {
+	channel := info.Channel
+	if channel == "" {
+		channel = info.Uploader
+	}
+
+	uploadDate := info.UploadDate
+	if t, err := time.Parse("20060102", info.UploadDate); err == nil {
+		uploadDate = t.Format("2006-01-02")
+	}
+
+	return &VideoMetadata{
+		Title:      info.Title,
+		Channel:    channel,
+		Duration:   info.Duration,
+		Thumbnail:  info.Thumbnail,
+		UploadDate: uploadDate,
+		Language:   info.Language,
+	}
+}
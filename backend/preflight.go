@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 処理可能な動画の長さの上限（分）
+var maxVideoMinutes = getEnvInt("MAX_VIDEO_DURATION_MINUTES", 120)
+
+// 事前チェックで動画を受け付けない理由
+type preflightError struct {
+	Status  int    // 返すHTTPステータス
+	Reason  string // 機械判定用の理由コード
+	Message string // 表示用メッセージ
+}
+
+func (e *preflightError) Error() string {
+	return e.Message
+}
+
+// 動画の事前チェック（メタデータ取得・ライブ/プレイリスト/非公開/長さの判定）
+func preflightVideo(ctx context.Context, rawURL string, opts VideoOptions) (*ytdlpInfo, *preflightError) {
+	if isPlaylistURL(rawURL) {
+		return nil, &preflightError{http.StatusUnprocessableEntity, "playlist", "プレイリスト・チャンネルのURLは受け付けていません。動画のURLを指定してください"}
+	}
+
+	info, err := fetchVideoInfo(ctx, rawURL, opts.ytdlp())
+	if err != nil {
+		if reason, message, ok := classifyYtdlpError(err); ok {
+			return nil, &preflightError{http.StatusUnprocessableEntity, reason, message}
+		}
+		return nil, &preflightError{http.StatusBadGateway, "metadata_unavailable", fmt.Sprintf("動画情報を取得できませんでした: %v", err)}
+	}
+	if perr := checkVideoInfo(info, opts); perr != nil {
+		return nil, perr
+	}
+
+	// YouTube字幕を使う場合は音声認識しない可能性があるため、残り使用量のチェックは処理時に行う
+	if !opts.UseCaptions {
+		minutes := Video{Options: opts, Metadata: info.metadata()}.speechMinutes()
+		if remaining := remainingSpeechMinutes(); minutes > remaining {
+			return nil, &preflightError{http.StatusTooManyRequests, "speech_quota_exceeded", fmt.Sprintf("Google Speech-to-Textの今月の残り時間が足りません（動画%d分 > 残り%d分）", minutes, remaining)}
+		}
+	}
+
+	return info, nil
+}
+
+// 取得済みのメタデータで処理できる動画か判定（ライブ/プレイリスト/非公開/年齢制限/長さ）
+// バッチ登録時はプレイリスト展開の結果（長さなどが欠けている場合あり）にも使う
+func checkVideoInfo(info *ytdlpInfo, opts VideoOptions) *preflightError {
+	if info.Type == "playlist" {
+		return &preflightError{http.StatusUnprocessableEntity, "playlist", "プレイリスト・チャンネルのURLは受け付けていません。動画のURLを指定してください"}
+	}
+	if info.IsLive || info.LiveStatus == "is_live" || info.LiveStatus == "is_upcoming" {
+		return &preflightError{http.StatusUnprocessableEntity, "live", "ライブ配信中・配信予定の動画は処理できません"}
+	}
+	switch info.Availability {
+	case "private", "premium_only", "subscriber_only", "needs_auth":
+		return &preflightError{http.StatusUnprocessableEntity, info.Availability, fmt.Sprintf("公開されていない動画のため処理できません（%s）", info.Availability)}
+	}
+	if info.AgeLimit >= 18 {
+		return &preflightError{http.StatusUnprocessableEntity, "age_restricted", "年齢制限付きの動画のため処理できません"}
+	}
+
+	if err := opts.validateClip(info.Duration); err != nil {
+		return &preflightError{http.StatusBadRequest, "invalid_clip", err.Error()}
+	}
+
+	// 切り出し範囲がある場合はその長さで判定
+	minutes := Video{Options: opts, Metadata: info.metadata()}.speechMinutes()
+	if maxVideoMinutes > 0 && minutes > maxVideoMinutes {
+		return &preflightError{http.StatusUnprocessableEntity, "too_long", fmt.Sprintf("動画が長すぎます（%d分 > 上限%d分）", minutes, maxVideoMinutes)}
+	}
+	return nil
+}
+
+// プレイリスト・チャンネルのURLか判定（watch?v=...&list=... は動画として扱う）
+func isPlaylistURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	if path == "/playlist" {
+		return true
+	}
+	for _, prefix := range []string{"/@", "/channel/", "/c/", "/user/"} {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return u.Query().Get("list") != "" && u.Query().Get("v") == "" && !strings.Contains(u.Host, "youtu.be")
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NTSC系のフレームレート（23.976などの表記を正確な値に置き換える）
+var ntscFrameRates = []float64{24000.0 / 1001, 30000.0 / 1001, 60000.0 / 1001}
+
+// 字幕全体の時刻補正（書き出し時のオプションと保存の両方で使う）
+// 変換後の時刻 = 元の時刻 × stretch × from_fps / to_fps + offset
+type retimeOptions struct {
+	Offset  float64 `json:"offset" form:"offset"`     // ずらす秒数（負の値で早める）
+	Stretch float64 `json:"stretch" form:"stretch"`   // 時間の伸縮率（0・省略時は1）
+	FromFPS float64 `json:"from_fps" form:"from_fps"` // 字幕を合わせた動画のフレームレート（例: 23.976）
+	ToFPS   float64 `json:"to_fps" form:"to_fps"`     // 変換先のフレームレート（例: 25）
+}
+
+// 補正を指定しているか
+func (o retimeOptions) enabled() bool {
+	return o.Offset != 0 || o.factor() != 1
+}
+
+// 補正を検証
+func (o retimeOptions) validate() error {
+	if o.Stretch < 0 || math.IsNaN(o.Stretch) || math.IsInf(o.Stretch, 0) {
+		return fmt.Errorf("stretchは正の数で指定してください")
+	}
+	if math.IsNaN(o.Offset) || math.IsInf(o.Offset, 0) {
+		return fmt.Errorf("offsetが不正です")
+	}
+	if (o.FromFPS == 0) != (o.ToFPS == 0) {
+		return fmt.Errorf("from_fpsとto_fpsは両方指定してください")
+	}
+	if o.FromFPS < 0 || o.ToFPS < 0 {
+		return fmt.Errorf("from_fps・to_fpsは正の数で指定してください")
+	}
+	return nil
+}
+
+// 時間の倍率（伸縮率とフレームレート変換をまとめたもの）
+func (o retimeOptions) factor() float64 {
+	factor := 1.0
+	if o.Stretch > 0 {
+		factor = o.Stretch
+	}
+	if o.FromFPS > 0 && o.ToFPS > 0 {
+		factor *= normalizeFPS(o.FromFPS) / normalizeFPS(o.ToFPS)
+	}
+	return factor
+}
+
+// 23.976・29.97・59.94を1001で割る正確な値にする（長い動画でずれが積み重ならないように）
+func normalizeFPS(fps float64) float64 {
+	for _, rate := range ntscFrameRates {
+		if math.Abs(fps-rate) < 0.01 {
+			return rate
+		}
+	}
+	return fps
+}
+
+// セグメントの時刻を補正した新しいスライスを返す
+// 0秒より前になった部分は切り詰め、すべて0秒より前になったセグメントは除く
+func retimeSegments(segments []SubtitleSegment, opts retimeOptions) []SubtitleSegment {
+	factor := opts.factor()
+	convert := func(t float64) float64 {
+		return t*factor + opts.Offset
+	}
+
+	retimed := make([]SubtitleSegment, 0, len(segments))
+	for _, seg := range segments {
+		seg.StartTime = math.Max(convert(seg.StartTime), 0)
+		seg.EndTime = convert(seg.EndTime)
+		if seg.EndTime <= 0 {
+			continue
+		}
+		if seg.Words != nil {
+			words := make([]WordInfo, 0, len(seg.Words))
+			for _, w := range seg.Words {
+				w.StartTime = math.Max(convert(w.StartTime), 0)
+				w.EndTime = convert(w.EndTime)
+				if w.EndTime > 0 {
+					words = append(words, w)
+				}
+			}
+			seg.Words = words
+		}
+		retimed = append(retimed, seg)
+	}
+	return retimed
+}
+
+// POST /videos/:id/{transcript,translation}/retime - 時刻の補正を保存（リビジョンを記録）
+// 補正の値は保存せず、セグメントの時刻そのものを書き換える（繰り返すと補正が重なる）
+// 元に戻す場合は補正前のリビジョンを復元する
+func retimeDocument(docType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var opts retimeOptions
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := opts.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "reason": "invalid_retime_options"})
+			return
+		}
+		if !opts.enabled() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset・stretch・from_fps/to_fpsのいずれかを指定してください", "reason": "invalid_retime_options"})
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		videoID := c.Param("id")
+		docID, ok := resolveDocumentID(docType, videoID)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+		segments, _, _ := documentSegments(docType, videoID)
+
+		retimed := retimeSegments(segments, opts)
+		if len(retimed) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "補正するとすべてのセグメントが0秒より前になります", "reason": "invalid_retime_options"})
+			return
+		}
+
+		doc := setDocumentSegments(docType, docID, retimed)
+		rev := recordRevision(docType, docID, videoID, requestAuthor(c), "retime", retimed)
+		result := gin.H{
+			"revision": rev.Number,
+			"document": doc,
+			"applied":  opts,
+			"note":     "セグメントの時刻を書き換えました。補正は次回以降も重ねて適用されます。元に戻すにはrestore_revisionのリビジョンを復元してください",
+		}
+		if rev.Number > 1 {
+			result["restore_revision"] = rev.Number - 1
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 編集対象のドキュメント種別
+const (
+	docTranscript  = "transcript"
+	docTranslation = "translation"
+)
+
+// 字幕・翻訳の変更履歴（リビジョン）
+type Revision struct {
+	ID           string            `json:"id"`
+	Number       int               `json:"number"`        // ドキュメントごとの連番（1始まり）
+	DocumentType string            `json:"document_type"` // transcript / translation
+	DocumentID   string            `json:"document_id"`
+	VideoId      string            `json:"video_id"`
+	Author       string            `json:"author"`
+	Action       string            `json:"action"` // created, edit, split, merge, restore など
+	Segments     []SubtitleSegment `json:"segments"`
+	CreatedAt    string            `json:"created_at"`
+}
+
+// リビジョン一覧（segmentsを省略した表示用）
+type revisionSummary struct {
+	ID           string `json:"id"`
+	Number       int    `json:"number"`
+	Author       string `json:"author"`
+	Action       string `json:"action"`
+	SegmentCount int    `json:"segment_count"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// セグメント単位の差分
+type segmentDiff struct {
+	Op        string           `json:"op"`                   // added, removed, changed
+	FromIndex int              `json:"from_index,omitempty"` // 変更前のセグメント番号（1始まり）
+	ToIndex   int              `json:"to_index,omitempty"`   // 変更後のセグメント番号（1始まり）
+	From      *SubtitleSegment `json:"from,omitempty"`
+	To        *SubtitleSegment `json:"to,omitempty"`
+}
+
+// リビジョンテーブル（muで保護）
+var revisions = []Revision{}
+
+// リクエストの編集者名を取得（X-Authorヘッダー、未指定の場合はanonymous）
+func requestAuthor(c *gin.Context) string {
+	if author := strings.TrimSpace(c.GetHeader("X-Author")); author != "" {
+		return author
+	}
+	return "anonymous"
+}
+
+// リビジョンを記録（呼び出し側でmuをロック済み）
+func recordRevision(docType, docID, videoID, author, action string, segments []SubtitleSegment) Revision {
+	number := 1
+	for _, rev := range revisions {
+		if rev.DocumentType == docType && rev.DocumentID == docID {
+			number++
+		}
+	}
+
+	snapshot := make([]SubtitleSegment, len(segments))
+	copy(snapshot, segments)
+
+	rev := Revision{
+		ID:           uuid.New().String(),
+		Number:       number,
+		DocumentType: docType,
+		DocumentID:   docID,
+		VideoId:      videoID,
+		Author:       author,
+		Action:       action,
+		Segments:     snapshot,
+		CreatedAt:    time.Now().Format(time.RFC3339),
+	}
+	revisions = append(revisions, rev)
+	return rev
+}
+
+// ドキュメントのリビジョンを番号で検索（呼び出し側でmuをロック済み）
+func findRevision(docType, docID string, number int) (Revision, bool) {
+	for _, rev := range revisions {
+		if rev.DocumentType == docType && rev.DocumentID == docID && rev.Number == number {
+			return rev, true
+		}
+	}
+	return Revision{}, false
+}
+
+// 動画IDから編集対象ドキュメントのIDを解決（呼び出し側でmuをロック済み）
+func resolveDocumentID(docType, videoID string) (string, bool) {
+	switch docType {
+	case docTranscript:
+		for _, transcript := range transcripts {
+			if transcript.VideoId == videoID {
+				return transcript.ID, true
+			}
+		}
+	case docTranslation:
+		if i := findTranslationIndexByVideo(videoID); i >= 0 {
+			return translations[i].ID, true
+		}
+	}
+	return "", false
+}
+
+// GET /videos/:id/{transcript,translation}/revisions - リビジョン一覧
+func listRevisions(docType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		docID, ok := resolveDocumentID(docType, c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+
+		summaries := []revisionSummary{}
+		for _, rev := range revisions {
+			if rev.DocumentType == docType && rev.DocumentID == docID {
+				summaries = append(summaries, revisionSummary{
+					ID:           rev.ID,
+					Number:       rev.Number,
+					Author:       rev.Author,
+					Action:       rev.Action,
+					SegmentCount: len(rev.Segments),
+					CreatedAt:    rev.CreatedAt,
+				})
+			}
+		}
+		c.JSON(http.StatusOK, summaries)
+	}
+}
+
+// GET /videos/:id/{transcript,translation}/revisions/diff?from=N&to=M - リビジョン間の差分
+func diffRevisions(docType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		from, errFrom := strconv.Atoi(c.Query("from"))
+		to, errTo := strconv.Atoi(c.Query("to"))
+		if errFrom != nil || errTo != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fromとtoにリビジョン番号を指定してください"})
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		docID, ok := resolveDocumentID(docType, c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+
+		fromRev, ok := findRevision(docType, docID, from)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("リビジョン%dが見つかりません", from)})
+			return
+		}
+		toRev, ok := findRevision(docType, docID, to)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("リビジョン%dが見つかりません", to)})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"from":    from,
+			"to":      to,
+			"changes": diffSegments(fromRev.Segments, toRev.Segments),
+		})
+	}
+}
+
+// POST /videos/:id/{transcript,translation}/revisions/:rev/restore - リビジョンの復元
+func restoreRevision(docType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		number, err := strconv.Atoi(c.Param("rev"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "リビジョン番号は整数で指定してください"})
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		videoID := c.Param("id")
+		docID, ok := resolveDocumentID(docType, videoID)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+
+		rev, ok := findRevision(docType, docID, number)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("リビジョン%dが見つかりません", number)})
+			return
+		}
+
+		segments := make([]SubtitleSegment, len(rev.Segments))
+		copy(segments, rev.Segments)
+
+		doc := setDocumentSegments(docType, docID, segments)
+		newRev := recordRevision(docType, docID, videoID, requestAuthor(c), fmt.Sprintf("restore:%d", number), segments)
+		c.JSON(http.StatusOK, gin.H{"revision": newRev.Number, "document": doc})
+	}
+}
+
+// 2つのセグメント一覧の差分を計算（LCSで一致部分を求め、前後の削除・追加を変更としてまとめる）
+func diffSegments(from, to []SubtitleSegment) []segmentDiff {
+	n, m := len(from), len(to)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if sameSegment(from[i], to[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	changes := []segmentDiff{}
+	var removed, added []int
+
+	// 連続した削除と追加を対応付けて出力
+	flush := func() {
+		k := 0
+		for ; k < len(removed) && k < len(added); k++ {
+			f, t := from[removed[k]], to[added[k]]
+			changes = append(changes, segmentDiff{Op: "changed", FromIndex: removed[k] + 1, ToIndex: added[k] + 1, From: &f, To: &t})
+		}
+		for ; k < len(removed); k++ {
+			f := from[removed[k]]
+			changes = append(changes, segmentDiff{Op: "removed", FromIndex: removed[k] + 1, From: &f})
+		}
+		for ; k < len(added); k++ {
+			t := to[added[k]]
+			changes = append(changes, segmentDiff{Op: "added", ToIndex: added[k] + 1, To: &t})
+		}
+		removed, added = nil, nil
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && sameSegment(from[i], to[j]):
+			flush()
+			i++
+			j++
+		case j >= m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, i)
+			i++
+		default:
+			added = append(added, j)
+			j++
+		}
+	}
+	flush()
+
+	return changes
+}
+
+// 差分判定用にセグメントのタイミングとテキストを比較
+func sameSegment(a, b SubtitleSegment) bool {
+	return a.StartTime == b.StartTime && a.EndTime == b.EndTime && a.Text == b.Text
+}
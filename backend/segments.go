@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// セグメント編集リクエスト
+// action: "edit"（デフォルト）, "split", "merge", "choose"
+type segmentPatchRequest struct {
+	Action      string   `json:"action"`
+	Text        *string  `json:"text"`        // edit: 新しいテキスト
+	StartTime   *float64 `json:"start_time"`  // edit: 新しい開始時間（秒）
+	EndTime     *float64 `json:"end_time"`    // edit: 新しい終了時間（秒）
+	SplitAt     float64  `json:"split_at"`    // split: 分割位置（秒）
+	Texts       []string `json:"texts"`       // split: 分割後の2つのテキスト（省略時は単語数で按分）
+	Alternative int      `json:"alternative"` // choose: 本文と入れ替える候補の番号（1始まり）
+}
+
+// PATCH /videos/:id/transcript/segments/:n - 字幕セグメント編集
+func patchTranscriptSegment(c *gin.Context) {
+	id := c.Param("id")
+	n, req, ok := bindSegmentPatch(c)
+	if !ok {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i, transcript := range transcripts {
+		if transcript.VideoId == id {
+			segments, err := applySegmentPatch(transcript.Segments, n, req, transcript.Language)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			transcripts[i].Segments = segments
+			transcripts[i].TransriptSrt = joinSegmentTexts(segments, " ")
+			recordRevision(docTranscript, transcript.ID, id, requestAuthor(c), patchAction(req), segments)
+			c.JSON(http.StatusOK, transcripts[i])
+			return
+		}
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"error": "Transcript not found"})
+}
+
+// PATCH /videos/:id/translation/segments/:n - 翻訳セグメント編集
+func patchTranslationSegment(c *gin.Context) {
+	id := c.Param("id")
+	n, req, ok := bindSegmentPatch(c)
+	if !ok {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	i := findTranslationIndexByVideo(id)
+	if i < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Translation not found"})
+		return
+	}
+
+	segments, err := applySegmentPatch(translations[i].Segments, n, req, translations[i].TargetLang)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	translations[i].Segments = segments
+	translations[i].TranslatedSrt = joinSegmentTexts(segments, "\n")
+	recordRevision(docTranslation, translations[i].ID, id, requestAuthor(c), patchAction(req), segments)
+	c.JSON(http.StatusOK, translations[i])
+}
+
+// 動画IDから翻訳の位置を検索（呼び出し側でmuをロック済み、見つからない場合は-1）
+func findTranslationIndexByVideo(videoID string) int {
+	for _, transcript := range transcripts {
+		if transcript.VideoId != videoID {
+			continue
+		}
+		for i, translation := range translations {
+			if translation.TranscriptId == transcript.ID {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// リビジョンに記録する操作名
+func patchAction(req segmentPatchRequest) string {
+	if req.Action == "" {
+		return "edit"
+	}
+	return req.Action
+}
+
+// セグメント番号とリクエストボディを読み取る（エラー時はレスポンス送信済み）
+func bindSegmentPatch(c *gin.Context) (int, segmentPatchRequest, bool) {
+	var req segmentPatchRequest
+
+	n, err := strconv.Atoi(c.Param("n"))
+	if err != nil || n < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "セグメント番号は1以上の整数で指定してください"})
+		return 0, req, false
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, req, false
+	}
+	return n, req, true
+}
+
+// セグメント一覧に編集を適用し、新しいスライスを返す（nは1始まり、langは分割・結合するテキストの言語）
+func applySegmentPatch(segments []SubtitleSegment, n int, req segmentPatchRequest, lang string) ([]SubtitleSegment, error) {
+	idx := n - 1
+	if idx < 0 || idx >= len(segments) {
+		return nil, fmt.Errorf("セグメント%dは存在しません（全%d件）", n, len(segments))
+	}
+
+	updated := make([]SubtitleSegment, len(segments))
+	copy(updated, segments)
+
+	switch req.Action {
+	case "", "edit":
+		seg := &updated[idx]
+		if req.Text != nil && strings.TrimSpace(*req.Text) != seg.Text {
+			// 人が確認・修正したテキストなので認識の信頼度と単語情報は使わない
+			seg.Text = strings.TrimSpace(*req.Text)
+			seg.Confidence = 0
+			seg.Words = nil
+		}
+		if req.StartTime != nil {
+			seg.StartTime = *req.StartTime
+		}
+		if req.EndTime != nil {
+			seg.EndTime = *req.EndTime
+		}
+		if seg.StartTime != segments[idx].StartTime || seg.EndTime != segments[idx].EndTime {
+			// 時間を変えた場合は範囲外になった単語を除く（信頼度の確認で範囲外の単語を読まないように）
+			seg.Words = wordsBetween(seg.Words, seg.StartTime, seg.EndTime)
+		}
+
+	case "split":
+		seg := updated[idx]
+		if req.SplitAt <= seg.StartTime || req.SplitAt >= seg.EndTime {
+			return nil, fmt.Errorf("分割位置%.3f秒はセグメントの範囲（%.3f〜%.3f秒）の内側で指定してください", req.SplitAt, seg.StartTime, seg.EndTime)
+		}
+
+		var first, second string
+		switch len(req.Texts) {
+		case 0:
+			first, second = splitTextByRatio(seg.Text, (req.SplitAt-seg.StartTime)/(seg.EndTime-seg.StartTime))
+		case 2:
+			first, second = strings.TrimSpace(req.Texts[0]), strings.TrimSpace(req.Texts[1])
+		default:
+			return nil, fmt.Errorf("textsは2件で指定してください")
+		}
+
+		left := SubtitleSegment{StartTime: seg.StartTime, EndTime: req.SplitAt, Text: first}
+		right := SubtitleSegment{StartTime: req.SplitAt, EndTime: seg.EndTime, Text: second}
+		if len(req.Texts) == 0 {
+			// テキストを按分した場合は単語情報と信頼度も分割位置で分ける
+			left.Words = wordsBetween(seg.Words, seg.StartTime, req.SplitAt)
+			right.Words = wordsBetween(seg.Words, req.SplitAt, seg.EndTime)
+			left.Confidence = splitConfidence(left.Words, seg.Confidence)
+			right.Confidence = splitConfidence(right.Words, seg.Confidence)
+		}
+		updated = append(updated[:idx], append([]SubtitleSegment{left, right}, updated[idx+1:]...)...)
+
+	case "merge":
+		// セグメントnと次のセグメントを結合
+		if idx+1 >= len(updated) {
+			return nil, fmt.Errorf("セグメント%dの次のセグメントがないため結合できません", n)
+		}
+		merged := SubtitleSegment{
+			StartTime: updated[idx].StartTime,
+			EndTime:   updated[idx+1].EndTime,
+			Text:      strings.TrimSpace(updated[idx].Text + textSeparator(lang) + updated[idx+1].Text),
+		}
+		merged.Words, merged.Confidence = mergeConfidence(updated[idx], updated[idx+1])
+		updated = append(updated[:idx], append([]SubtitleSegment{merged}, updated[idx+2:]...)...)
+
+	case "choose":
+		// 候補を本文にし、元の本文は候補に戻す（選び直せるように）
+		seg := &updated[idx]
+		k := req.Alternative - 1
+		if k < 0 || k >= len(seg.Alternatives) {
+			return nil, fmt.Errorf("セグメント%dに候補%dはありません（全%d件）", n, req.Alternative, len(seg.Alternatives))
+		}
+		alternatives := make([]SegmentAlternative, len(seg.Alternatives))
+		copy(alternatives, seg.Alternatives)
+		chosen := alternatives[k]
+		alternatives[k] = SegmentAlternative{Text: seg.Text, Confidence: seg.Confidence}
+
+		// 人が選んだテキストなので、編集と同様に信頼度と単語情報は使わない
+		seg.Text = chosen.Text
+		seg.Confidence = 0
+		seg.Words = nil
+		seg.Alternatives = alternatives
+
+	default:
+		return nil, fmt.Errorf("不明なaction: %s（edit, split, merge, chooseのいずれか）", req.Action)
+	}
+
+	// 編集したセグメント（splitは2つ）と前後のセグメントだけを検証
+	last := idx
+	if req.Action == "split" {
+		last = idx + 1
+	}
+	if err := validateEditedTimings(segments, updated, idx, last); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// テキストを単語数の比率で2つに分割（空白のないテキストは文字数の比率で分割）
+func splitTextByRatio(text string, ratio float64) (string, string) {
+	words := strings.Fields(text)
+	if len(words) >= 2 {
+		cut := ratioCut(len(words), ratio)
+		return strings.Join(words[:cut], " "), strings.Join(words[cut:], " ")
+	}
+
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) < 2 {
+		return text, ""
+	}
+	cut := ratioCut(len(runes), ratio)
+	return string(runes[:cut]), string(runes[cut:])
+}
+
+// n個の要素を比率で分ける位置（両側に1つ以上残す）
+func ratioCut(n int, ratio float64) int {
+	cut := int(float64(n)*ratio + 0.5)
+	if cut < 1 {
+		cut = 1
+	}
+	if cut >= n {
+		cut = n - 1
+	}
+	return cut
+}
+
+// テキストを結合するときの区切り（日本語・中国語は単語を空白で区切らない）
+func textSeparator(lang string) string {
+	switch baseLanguage(lang) {
+	case "ja", "zh":
+		return ""
+	default:
+		return " "
+	}
+}
+
+// 編集後のセグメントfirst〜lastのタイミングを検証（開始<終了、時系列順）
+// 前後のセグメントとの重なりは、編集前からあったもの（取り込んだ字幕など）より大きくなる場合だけ拒否する
+// 文書の他の場所にある重なりで編集できなくならないよう、離れたセグメントは検証しない
+func validateEditedTimings(original, updated []SubtitleSegment, first, last int) error {
+	for i := first; i <= last; i++ {
+		seg := updated[i]
+		if seg.StartTime < 0 {
+			return fmt.Errorf("セグメント%dの開始時間が負の値です", i+1)
+		}
+		if seg.EndTime <= seg.StartTime {
+			return fmt.Errorf("セグメント%dの終了時間（%.3f秒）が開始時間（%.3f秒）以前です", i+1, seg.EndTime, seg.StartTime)
+		}
+		if i > first && seg.StartTime < updated[i-1].EndTime {
+			return fmt.Errorf("セグメント%d（%.3f秒〜）が前のセグメント（〜%.3f秒）と重なっています", i+1, seg.StartTime, updated[i-1].EndTime)
+		}
+	}
+
+	// 前のセグメントとの境界（前のセグメントの位置は編集前後で同じ）
+	if first > 0 {
+		before := segmentOverlap(original[first-1], original[first])
+		if after := segmentOverlap(updated[first-1], updated[first]); after > 0 && after > before {
+			return fmt.Errorf("セグメント%d（%.3f秒〜）が前のセグメント（〜%.3f秒）と重なっています", first+1, updated[first].StartTime, updated[first-1].EndTime)
+		}
+	}
+
+	// 次のセグメントとの境界（分割・結合で次のセグメントの位置がずれる）
+	if next := last + 1; next < len(updated) {
+		orig := next + len(original) - len(updated)
+		before := segmentOverlap(original[orig-1], original[orig])
+		if after := segmentOverlap(updated[last], updated[next]); after > 0 && after > before {
+			return fmt.Errorf("セグメント%d（〜%.3f秒）が次のセグメント（%.3f秒〜）と重なっています", last+1, updated[last].EndTime, updated[next].StartTime)
+		}
+	}
+	return nil
+}
+
+// 2つの連続したセグメントが重なっている長さ（秒、重なっていなければ0以下）
+func segmentOverlap(prev, next SubtitleSegment) float64 {
+	return prev.EndTime - next.StartTime
+}
+
+// セグメントのテキストを連結して全文を作成
+func joinSegmentTexts(segments []SubtitleSegment, sep string) string {
+	texts := make([]string, len(segments))
+	for i, seg := range segments {
+		texts[i] = seg.Text
+	}
+	return strings.Join(texts, sep)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// シャットダウン時に処理中のジョブ・リクエストを待つ最大時間
+var shutdownTimeout = time.Duration(getEnvInt("SHUTDOWN_TIMEOUT_SEC", 30)) * time.Second
+
+// 実行中のバックグラウンドジョブ
+var backgroundJobs sync.WaitGroup
+
+// バックグラウンドジョブを開始（シャットダウン時に終了を待てるように登録）
+func goJob(fn func()) {
+	backgroundJobs.Add(1)
+	go func() {
+		defer backgroundJobs.Done()
+		fn()
+	}()
+}
+
+// バックグラウンドジョブの終了を待つ（タイムアウトした場合はfalse）
+func waitJobs(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		backgroundJobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/speech/apiv1/speechpb"
+)
+
+// 音声認識の設定（環境変数で上書き可能、ジョブごとに上書きできるものはVideoOptionsで指定）
+var (
+	speechSyncMaxSeconds  = float64(getEnvInt("SPEECH_SYNC_MAX_SECONDS", 55)) // 同期認識を使う長さの上限（同期認識は1分までのため余裕を持たせる）
+	speechAPIVersion      = getEnv("SPEECH_API_VERSION", speechAPIv1)         // 使用するAPI（v1, v2）
+	speechModel           = getEnv("SPEECH_MODEL", "")                        // モデル（空ならAPIの既定）
+	speechLanguage        = getEnv("SPEECH_LANGUAGE", "en-US")                // 認識する言語（字幕の言語としても記録）
+	speechUseEnhanced     = getEnv("SPEECH_USE_ENHANCED", "false") == "true"  // v1の拡張モデルを使うか
+	speechMaxAlternatives = getEnvInt("SPEECH_MAX_ALTERNATIVES", 3)           // セグメントごとに受け取る候補数（1なら候補を保存しない）
+)
+
+// Speech-to-TextのAPIバージョン
+const (
+	speechAPIv1 = "v1"
+	speechAPIv2 = "v2"
+)
+
+// APIごとに指定できるモデルと既定のモデル
+var (
+	speechModels = map[string]map[string]bool{
+		speechAPIv1: {"default": true, "latest_long": true, "latest_short": true, "video": true, "phone_call": true, "command_and_search": true, "telephony": true, "telephony_short": true},
+		speechAPIv2: {"long": true, "short": true, "telephony": true, "telephony_short": true, "chirp": true, "chirp_2": true, "chirp_3": true, "latest_long": true, "latest_short": true},
+	}
+	speechDefaultModels = map[string]string{
+		speechAPIv1: "default",
+		speechAPIv2: "long",
+	}
+	// globalでは使えず、リージョンを指定する必要があるv2のモデルと対応リージョン
+	speechV2ModelLocations = map[string][]string{
+		"chirp":   {"us-central1", "europe-west4", "asia-southeast1"},
+		"chirp_2": {"us-central1", "europe-west4", "asia-southeast1"},
+		"chirp_3": {"us", "eu"},
+	}
+)
+
+// 1つのジョブで使う音声認識の設定
+type speechOptions struct {
+	API         string
+	Model       string
+	UseEnhanced bool
+	Language    string
+}
+
+// 動画に適用する音声認識の設定（環境変数の設定にジョブごとの指定を重ねる）
+func (o VideoOptions) speech() speechOptions {
+	opts := speechOptions{API: speechAPIVersion, Model: speechModel, UseEnhanced: speechUseEnhanced, Language: speechLanguage}
+	if o.SpeechAPI != "" && o.SpeechAPI != opts.API {
+		// APIを変えた場合、環境変数のモデルは別APIのものなので使わない
+		opts.API = o.SpeechAPI
+		opts.Model = ""
+	}
+	if o.SpeechModel != "" {
+		opts.Model = o.SpeechModel
+	}
+	if o.UseEnhanced != nil {
+		opts.UseEnhanced = *o.UseEnhanced
+	}
+	if opts.Model == "" {
+		opts.Model = speechDefaultModels[opts.API]
+	}
+	return opts
+}
+
+// APIとモデルの組み合わせを検証
+func (o speechOptions) validate() error {
+	models, ok := speechModels[o.API]
+	if !ok {
+		return fmt.Errorf("speech_apiはv1またはv2を指定してください: %s", o.API)
+	}
+	if !models[o.Model] {
+		names := make([]string, 0, len(models))
+		for name := range models {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("speech_model（%s）は%sでは使えません（%s）", o.Model, o.API, strings.Join(names, ", "))
+	}
+	if o.UseEnhanced && o.API != speechAPIv1 {
+		return fmt.Errorf("use_enhancedはv1でのみ指定できます")
+	}
+	if locations, ok := speechV2ModelLocations[o.Model]; ok && o.API == speechAPIv2 && !slices.Contains(locations, speechV2Location) {
+		return fmt.Errorf("speech_model（%s）はSPEECH_V2_LOCATION=%sでは使えません（%sのいずれかを設定してください）", o.Model, speechV2Location, strings.Join(locations, ", "))
+	}
+	return nil
+}
+
+// 音声認識の設定（同期・長時間認識で共通）
+func speechRecognitionConfig(opts speechOptions) *speechpb.RecognitionConfig {
+	config := &speechpb.RecognitionConfig{
+		Encoding:              speechpb.RecognitionConfig_MP3, // MP3形式
+		SampleRateHertz:       44100,                          // サンプルレート
+		LanguageCode:          opts.Language,                  // 言語設定
+		EnableWordTimeOffsets: true,                           // 単語レベルのタイムスタンプ
+		EnableWordConfidence:  true,                           // 単語レベルの信頼度
+		MaxAlternatives:       int32(speechMaxAlternatives),   // 候補数（先頭が最も確からしい結果）
+		UseEnhanced:           opts.UseEnhanced,
+	}
+	if opts.Model != speechDefaultModels[speechAPIv1] {
+		config.Model = opts.Model
+	}
+	return config
+}
+
+// 音声ファイルを認識（APIと長さに応じて同期認識・長時間認識を選ぶ）
+func recognizeFile(ctx context.Context, audioFile string, durationSec float64, size int64, opts speechOptions) (string, []SubtitleSegment, error) {
+	short := useSyncRecognize(durationSec, size)
+	if short {
+		log.Printf("同期音声認識: %.1f秒, API=%s, モデル=%s", durationSec, opts.API, opts.Model)
+	}
+
+	if opts.API == speechAPIv2 {
+		if short {
+			return recognizeShortV2(ctx, audioFile, opts)
+		}
+		return recognizeLongV2(ctx, audioFile, opts)
+	}
+
+	var results []*speechpb.SpeechRecognitionResult
+	var err error
+	if short {
+		results, err = recognizeShort(ctx, audioFile, opts)
+	} else {
+		results, err = recognizeLong(ctx, audioFile, opts)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	text, segments := speechResultsToSegments(results)
+	return text, segments, nil
+}
+
+// 認識結果の1つの候補（v1/v2の結果をこの形にそろえてからセグメントを作る）
+type recognizedAlternative struct {
+	Transcript string
+	Confidence float64
+	Words      []WordInfo
+}
+
+// v1の認識結果をテキストとセグメントに変換
+func speechResultsToSegments(results []*speechpb.SpeechRecognitionResult) (string, []SubtitleSegment) {
+	recognized := make([][]recognizedAlternative, len(results))
+	for i, result := range results {
+		for _, alt := range result.Alternatives {
+			words := make([]WordInfo, len(alt.Words))
+			for j, w := range alt.Words {
+				words[j] = WordInfo{
+					Word:       w.Word,
+					StartTime:  w.StartTime.AsDuration().Seconds(),
+					EndTime:    w.EndTime.AsDuration().Seconds(),
+					Confidence: float64(w.Confidence),
+				}
+			}
+			recognized[i] = append(recognized[i], recognizedAlternative{Transcript: alt.Transcript, Confidence: float64(alt.Confidence), Words: words})
+		}
+	}
+	return buildSpeechSegments(recognized)
+}
+
+// 結果ごとの候補からテキストとセグメントを作成（v1/v2共通）
+func buildSpeechSegments(results [][]recognizedAlternative) (string, []SubtitleSegment) {
+	var transcriptText string
+	var segments []SubtitleSegment
+
+	for _, alternatives := range results {
+		if len(alternatives) == 0 {
+			continue
+		}
+		// 本文には最も確からしい候補だけを使い、残りは候補として保存
+		alt := alternatives[0]
+		transcriptText += alt.Transcript + " "
+
+		// 単語レベルのタイムスタンプから文レベルのセグメントを作成
+		if len(alt.Words) > 0 {
+			var others []SegmentAlternative
+			for _, other := range alternatives[1:] {
+				others = appendAlternative(others, other.Transcript, other.Confidence)
+			}
+
+			segments = append(segments, SubtitleSegment{
+				StartTime:    alt.Words[0].StartTime,
+				EndTime:      alt.Words[len(alt.Words)-1].EndTime,
+				Text:         strings.TrimSpace(alt.Transcript),
+				Confidence:   alt.Confidence,
+				Words:        alt.Words,
+				Alternatives: others,
+			})
+		}
+	}
+	return transcriptText, segments
+}
+
+// 候補を追加（空・重複は除く）
+func appendAlternative(alternatives []SegmentAlternative, transcript string, confidence float64) []SegmentAlternative {
+	text := strings.TrimSpace(transcript)
+	if text == "" {
+		return alternatives
+	}
+	for _, a := range alternatives {
+		if a.Text == text {
+			return alternatives
+		}
+	}
+	return append(alternatives, SegmentAlternative{Text: text, Confidence: confidence})
+}
+
+// 同期認識を使えるか（長さが分かっていて短く、直接送れるサイズの場合のみ）
+func useSyncRecognize(durationSec float64, size int64) bool {
+	return durationSec > 0 && durationSec <= speechSyncMaxSeconds && size <= maxInlineAudioBytes
+}
+
+// 短い音声を同期認識（音声をリクエストに直接含めるためアップロード不要）
+func recognizeShort(ctx context.Context, audioFile string, opts speechOptions) ([]*speechpb.SpeechRecognitionResult, error) {
+	client, err := speechClient()
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(audioFile)
+	if err != nil {
+		return nil, fmt.Errorf("ファイル読み込みエラー: %v", err)
+	}
+
+	resp, err := client.Recognize(ctx, &speechpb.RecognizeRequest{
+		Config: speechRecognitionConfig(opts),
+		Audio: &speechpb.RecognitionAudio{
+			AudioSource: &speechpb.RecognitionAudio_Content{Content: content},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("音声認識エラー: %v", err)
+	}
+	return resp.Results, nil
+}
+
+// 長い音声を長時間認識（一時保存先にアップロードして完了を待つ）
+func recognizeLong(ctx context.Context, audioFile string, opts speechOptions) ([]*speechpb.SpeechRecognitionResult, error) {
+	client, err := speechClient()
+	if err != nil {
+		return nil, err
+	}
+	audio, cleanup, err := stageSpeechAudio(ctx, audioFile)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	op, err := client.LongRunningRecognize(ctx, &speechpb.LongRunningRecognizeRequest{
+		Config: speechRecognitionConfig(opts),
+		Audio:  audio,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("音声認識開始エラー: %v", err)
+	}
+
+	// 処理完了を待機
+	resp, err := op.Wait(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("音声認識エラー: %v", err)
+	}
+	return resp.Results, nil
+}
+
+// 音声認識に渡す音声を準備する関数
+// 長時間認識はGCS上の音声しか読めないため、一時保存先（GCSのみ対応）にアップロードしてURIを渡す
+func stageSpeechAudio(ctx context.Context, audioFile string) (*speechpb.RecognitionAudio, func(), error) {
+	uri, cleanup, err := uploadSpeechAudio(ctx, audioFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	audio := &speechpb.RecognitionAudio{
+		AudioSource: &speechpb.RecognitionAudio_Uri{Uri: uri},
+	}
+	return audio, cleanup, nil
+}
+
+// 音声をGCSの一時保存先にアップロードしてURIと削除用の関数を返す
+func uploadSpeechAudio(ctx context.Context, audioFile string) (string, func(), error) {
+	if _, ok := audioStore.(*gcsStore); !ok {
+		return "", nil, fmt.Errorf("長い音声の認識にはGCSの一時保存先が必要です（AUDIO_STORAGE_BUCKETを設定してください）")
+	}
+
+	key := fmt.Sprintf("audio/%s", filepath.Base(audioFile))
+	uri, err := putFile(ctx, audioStore, key, audioFile, contentTypeFor(audioFile))
+	if err != nil {
+		return "", nil, fmt.Errorf("GCSアップロードエラー: %v", err)
+	}
+	log.Printf("GCSアップロード完了: %s", uri)
+
+	// 処理完了後、GCSファイルを削除（無料枠節約のため）
+	cleanup := func() {
+		if err := audioStore.Delete(ctx, key); err != nil {
+			log.Printf("GCS削除エラー（続行）: %v", err)
+		}
+	}
+	return uri, cleanup, nil
+}
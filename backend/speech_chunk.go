@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 長い音声の分割認識の設定（環境変数で上書き可能）
+var (
+	ffmpegPath               = getEnv("FFMPEG_PATH", "ffmpeg")                                      // ffmpegのバイナリパス
+	speechChunkMinSeconds    = float64(getEnvInt("SPEECH_CHUNK_MIN_SECONDS", 600))                  // この長さを超える音声を分割（0で分割しない）
+	speechChunkTargetSeconds = float64(getEnvInt("SPEECH_CHUNK_TARGET_SECONDS", 300))               // 1チャンクの目安の長さ
+	speechChunkMaxSeconds    = float64(getEnvInt("SPEECH_CHUNK_MAX_SECONDS", 420))                  // 無音が見つからない場合に強制的に切る長さ
+	speechChunkOverlap       = float64(getEnvInt("SPEECH_CHUNK_OVERLAP_MS", 500)) / 1000            // 切れ目の前後に含める余白（秒）
+	speechChunkConcurrency   = getEnvInt("SPEECH_CHUNK_CONCURRENCY", 3)                             // 同時に認識するチャンク数
+	speechChunkRetries       = getEnvInt("SPEECH_CHUNK_RETRIES", 2)                                 // チャンクごとのリトライ回数
+	silenceNoise             = getEnv("SPEECH_SILENCE_NOISE", "-30dB")                              // 無音とみなす音量
+	silenceMinDuration       = getEnv("SPEECH_SILENCE_MIN_DURATION", "0.5")                         // 無音とみなす最短の長さ（秒）
+	ffmpegTimeout            = time.Duration(getEnvInt("FFMPEG_TIMEOUT_MINUTES", 10)) * time.Minute // ffmpeg 1回あたりのタイムアウト
+)
+
+// silencedetectの出力（例: "[silencedetect @ 0x...] silence_start: 12.345"）
+var (
+	silenceStartPattern = regexp.MustCompile(`silence_start: (-?[\d.]+)`)
+	silenceEndPattern   = regexp.MustCompile(`silence_end: ([\d.]+)`)
+	ffmpegDurationLine  = regexp.MustCompile(`Duration: (\d+):(\d+):([\d.]+)`)
+)
+
+// 無音区間（秒）
+type silence struct {
+	Start float64
+	End   float64
+}
+
+// 分割した音声の1チャンク
+// Start〜Endが担当範囲で、実際に切り出す音声は前後に余白を含む
+type audioChunk struct {
+	Index int
+	Start float64
+	End   float64
+}
+
+// 分割認識を使うか（長さが分かっていて十分長い場合のみ）
+func useChunkedRecognize(durationSec float64) bool {
+	return speechChunkMinSeconds > 0 && durationSec > speechChunkMinSeconds
+}
+
+// 音声を無音で分割し、並列に認識して1つのセグメント列につなげる
+func transcribeChunked(ctx context.Context, audioFile string, durationSec float64, opts speechOptions) ([]SubtitleSegment, error) {
+	silences, probed, err := detectSilences(ctx, audioFile)
+	if err != nil {
+		return nil, err
+	}
+	if probed > 0 {
+		durationSec = probed
+	}
+
+	chunks := planChunks(silences, durationSec, speechChunkTargetSeconds, speechChunkMaxSeconds)
+	log.Printf("音声を%dチャンクに分割して認識: %.1f秒, 無音区間%d件", len(chunks), durationSec, len(silences))
+
+	concurrency := speechChunkConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([][]SubtitleSegment, len(chunks))
+	errs := make([]error, len(chunks))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for _, chunk := range chunks {
+		wg.Add(1)
+		go func(chunk audioChunk) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			segments, err := transcribeChunk(ctx, audioFile, chunk, durationSec, opts)
+			if err != nil {
+				errs[chunk.Index] = fmt.Errorf("チャンク%d（%.1f〜%.1f秒）認識エラー: %v", chunk.Index, chunk.Start, chunk.End, err)
+				return
+			}
+			results[chunk.Index] = segments
+		}(chunk)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return stitchChunks(chunks, results), nil
+}
+
+// 1チャンクを切り出して認識（失敗した場合はリトライ）
+func transcribeChunk(ctx context.Context, audioFile string, chunk audioChunk, durationSec float64, opts speechOptions) ([]SubtitleSegment, error) {
+	start := chunk.Start - speechChunkOverlap
+	if start < 0 {
+		start = 0
+	}
+	end := chunk.End + speechChunkOverlap
+	if durationSec > 0 && end > durationSec {
+		end = durationSec
+	}
+
+	ext := filepath.Ext(audioFile)
+	chunkFile := fmt.Sprintf("%s.chunk%03d%s", strings.TrimSuffix(audioFile, ext), chunk.Index, ext)
+	if err := extractAudio(ctx, audioFile, chunkFile, start, end); err != nil {
+		return nil, err
+	}
+	defer os.Remove(chunkFile)
+
+	info, err := os.Stat(chunkFile)
+	if err != nil {
+		return nil, fmt.Errorf("ファイル情報取得エラー: %v", err)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= speechChunkRetries; attempt++ {
+		if attempt > 0 {
+			delay := backoffDelay(attempt - 1)
+			log.Printf("チャンク%d再認識待機: %v（%d/%d回目, 前回エラー: %v）", chunk.Index, delay, attempt, speechChunkRetries, lastErr)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		var segments []SubtitleSegment
+		_, segments, lastErr = recognizeFile(ctx, chunkFile, end-start, info.Size(), opts)
+		if lastErr == nil {
+			// チャンク内の時刻を元の音声の時刻に戻す
+			return shiftSegments(segments, start), nil
+		}
+	}
+	return nil, fmt.Errorf("リトライ上限（%d回）に達しました: %w", speechChunkRetries, lastErr)
+}
+
+// ffmpegで無音区間と音声の長さを検出
+func detectSilences(ctx context.Context, audioFile string) ([]silence, float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, ffmpegTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, ffmpegPath,
+		"-hide_banner", "-nostats",
+		"-i", audioFile,
+		"-af", fmt.Sprintf("silencedetect=noise=%s:d=%s", silenceNoise, silenceMinDuration),
+		"-f", "null", "-",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, 0, fmt.Errorf("無音検出エラー: %v: %s", err, summarizeStderr(stderr.String()))
+	}
+
+	silences, duration := parseSilenceDetect(stderr.String())
+	return silences, duration, nil
+}
+
+// silencedetectの出力を解析（末尾まで続く無音はdurationで閉じる）
+func parseSilenceDetect(output string) ([]silence, float64) {
+	var silences []silence
+	var duration float64
+	open := -1.0
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := ffmpegDurationLine.FindStringSubmatch(line); m != nil && duration == 0 {
+			h, _ := strconv.Atoi(m[1])
+			mins, _ := strconv.Atoi(m[2])
+			sec, _ := strconv.ParseFloat(m[3], 64)
+			duration = float64(h*3600+mins*60) + sec
+		}
+		if m := silenceStartPattern.FindStringSubmatch(line); m != nil {
+			open, _ = strconv.ParseFloat(m[1], 64)
+			if open < 0 {
+				open = 0
+			}
+		}
+		if m := silenceEndPattern.FindStringSubmatch(line); m != nil && open >= 0 {
+			end, _ := strconv.ParseFloat(m[1], 64)
+			silences = append(silences, silence{Start: open, End: end})
+			open = -1
+		}
+	}
+	if open >= 0 && duration > open {
+		silences = append(silences, silence{Start: open, End: duration})
+	}
+	return silences, duration
+}
+
+// 無音区間の中央で区切ってチャンクを決める
+// 目安の長さの半分〜上限の範囲で目安に最も近い無音を選び、無音がなければ上限で切る
+func planChunks(silences []silence, duration, target, limit float64) []audioChunk {
+	if limit < target {
+		limit = target
+	}
+
+	var chunks []audioChunk
+	start := 0.0
+	for duration-start > limit {
+		cut := start + limit
+		best := -1.0
+		for _, s := range silences {
+			mid := (s.Start + s.End) / 2
+			length := mid - start
+			if length < target/2 || length > limit {
+				continue
+			}
+			if best < 0 || math.Abs(length-target) < math.Abs(best-start-target) {
+				best = mid
+			}
+		}
+		if best > 0 {
+			cut = best
+		}
+		chunks = append(chunks, audioChunk{Index: len(chunks), Start: start, End: cut})
+		start = cut
+	}
+	return append(chunks, audioChunk{Index: len(chunks), Start: start, End: duration})
+}
+
+// ffmpegで音声の一部を切り出す
+func extractAudio(ctx context.Context, src, dst string, start, end float64) error {
+	ctx, cancel := context.WithTimeout(ctx, ffmpegTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, ffmpegPath,
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-ss", strconv.FormatFloat(start, 'f', 3, 64),
+		"-to", strconv.FormatFloat(end, 'f', 3, 64),
+		"-i", src,
+		"-vn", "-ar", "44100", "-c:a", "libmp3lame",
+		dst,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("音声切り出しエラー: %v: %s", err, summarizeStderr(stderr.String()))
+	}
+	return nil
+}
+
+// チャンクごとの結果を1つにつなげる
+// 余白部分で重複したセグメントは、中央の時刻が担当範囲に入るチャンクのものだけを残す
+func stitchChunks(chunks []audioChunk, results [][]SubtitleSegment) []SubtitleSegment {
+	var stitched []SubtitleSegment
+	for i, chunk := range chunks {
+		last := i == len(chunks)-1
+		for _, seg := range results[i] {
+			mid := (seg.StartTime + seg.EndTime) / 2
+			if mid < chunk.Start || (!last && mid >= chunk.End) {
+				continue
+			}
+			stitched = append(stitched, seg)
+		}
+	}
+
+	sort.SliceStable(stitched, func(i, j int) bool {
+		return stitched[i].StartTime < stitched[j].StartTime
+	})
+
+	// 境界をまたいで同じ文が両方のチャンクに残った場合は1つにまとめる
+	var deduped []SubtitleSegment
+	for _, seg := range stitched {
+		if n := len(deduped); n > 0 {
+			prev := &deduped[n-1]
+			if seg.StartTime < prev.EndTime && strings.EqualFold(strings.TrimSpace(seg.Text), strings.TrimSpace(prev.Text)) {
+				if seg.EndTime > prev.EndTime {
+					prev.EndTime = seg.EndTime
+				}
+				continue
+			}
+		}
+		deduped = append(deduped, seg)
+	}
+	return deduped
+}
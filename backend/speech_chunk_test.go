@@ -0,0 +1,141 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSilenceDetect(t *testing.T) {
+	output := "Input #0, mp3, from 'audio.mp3':\n" +
+		"  Duration: 00:10:05.50, start: 0.000000, bitrate: 128 kb/s\n" +
+		"[silencedetect @ 0x1] silence_end: 0.2 | silence_duration: 0.2\n" +
+		"[silencedetect @ 0x1] silence_start: -0.01\n" +
+		"[silencedetect @ 0x1] silence_end: 1.5 | silence_duration: 1.51\n" +
+		"[silencedetect @ 0x1] silence_start: 100.25\n" +
+		"[silencedetect @ 0x1] silence_end: 101 | silence_duration: 0.75\n" +
+		"  Duration: 00:00:01.00, start: 0.000000, bitrate: 128 kb/s\n" +
+		"[silencedetect @ 0x1] silence_start: 600\n"
+
+	silences, duration := parseSilenceDetect(output)
+	if duration != 605.5 {
+		t.Errorf("duration = %v, want 605.5", duration)
+	}
+	want := []silence{{Start: 0, End: 1.5}, {Start: 100.25, End: 101}, {Start: 600, End: 605.5}}
+	if !reflect.DeepEqual(silences, want) {
+		t.Errorf("silences = %+v, want %+v", silences, want)
+	}
+}
+
+func TestPlanChunks(t *testing.T) {
+	tests := []struct {
+		name     string
+		silences []silence
+		duration float64
+		target   float64
+		limit    float64
+		want     []audioChunk
+	}{
+		{
+			name:     "short audio is one chunk",
+			duration: 400,
+			target:   300,
+			limit:    420,
+			want:     []audioChunk{{Index: 0, Start: 0, End: 400}},
+		},
+		{
+			name:     "no silence cuts at the limit",
+			duration: 1000,
+			target:   300,
+			limit:    420,
+			want:     []audioChunk{{Index: 0, Start: 0, End: 420}, {Index: 1, Start: 420, End: 840}, {Index: 2, Start: 840, End: 1000}},
+		},
+		{
+			name:     "cuts at the middle of silences",
+			silences: []silence{{Start: 295, End: 305}, {Start: 590, End: 610}},
+			duration: 800,
+			target:   300,
+			limit:    420,
+			want:     []audioChunk{{Index: 0, Start: 0, End: 300}, {Index: 1, Start: 300, End: 600}, {Index: 2, Start: 600, End: 800}},
+		},
+		{
+			name:     "prefers the silence closest to the target",
+			silences: []silence{{Start: 199, End: 201}, {Start: 319, End: 321}},
+			duration: 500,
+			target:   300,
+			limit:    420,
+			want:     []audioChunk{{Index: 0, Start: 0, End: 320}, {Index: 1, Start: 320, End: 500}},
+		},
+		{
+			name:     "ignores silences shorter than half the target or past the limit",
+			silences: []silence{{Start: 99, End: 101}, {Start: 449, End: 451}},
+			duration: 500,
+			target:   300,
+			limit:    420,
+			want:     []audioChunk{{Index: 0, Start: 0, End: 420}, {Index: 1, Start: 420, End: 500}},
+		},
+		{
+			name:     "limit below target is raised to target",
+			duration: 700,
+			target:   300,
+			limit:    100,
+			want:     []audioChunk{{Index: 0, Start: 0, End: 300}, {Index: 1, Start: 300, End: 600}, {Index: 2, Start: 600, End: 700}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := planChunks(tt.silences, tt.duration, tt.target, tt.limit)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunks = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStitchChunks(t *testing.T) {
+	chunks := []audioChunk{{Index: 0, Start: 0, End: 10}, {Index: 1, Start: 10, End: 20}}
+
+	tests := []struct {
+		name    string
+		results [][]SubtitleSegment
+		want    []SubtitleSegment
+	}{
+		{
+			name: "keeps each segment in the chunk that owns its middle",
+			results: [][]SubtitleSegment{
+				{{StartTime: 1, EndTime: 2, Text: "a"}, {StartTime: 9.5, EndTime: 10.8, Text: "b"}},
+				{{StartTime: 9.3, EndTime: 9.6, Text: "x"}, {StartTime: 9.5, EndTime: 10.8, Text: "b"}, {StartTime: 15, EndTime: 16, Text: "c"}},
+			},
+			want: []SubtitleSegment{
+				{StartTime: 1, EndTime: 2, Text: "a"},
+				{StartTime: 9.5, EndTime: 10.8, Text: "b"},
+				{StartTime: 15, EndTime: 16, Text: "c"},
+			},
+		},
+		{
+			name: "same sentence on both sides of the boundary is merged",
+			results: [][]SubtitleSegment{
+				{{StartTime: 8.5, EndTime: 11, Text: "Same"}},
+				{{StartTime: 9.9, EndTime: 11.5, Text: "same "}},
+			},
+			want: []SubtitleSegment{{StartTime: 8.5, EndTime: 11.5, Text: "Same"}},
+		},
+		{
+			name: "last chunk keeps segments past its end",
+			results: [][]SubtitleSegment{
+				{},
+				{{StartTime: 19.5, EndTime: 20.8, Text: "tail"}},
+			},
+			want: []SubtitleSegment{{StartTime: 19.5, EndTime: 20.8, Text: "tail"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stitchChunks(chunks, tt.results)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("segments = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
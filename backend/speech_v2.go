@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	speechpb "cloud.google.com/go/speech/apiv2/speechpb"
+)
+
+// v2の音声認識の設定（recognizerは作成せず、リクエストごとに設定を渡す）
+func speechV2Config(opts speechOptions) *speechpb.RecognitionConfig {
+	return &speechpb.RecognitionConfig{
+		DecodingConfig: &speechpb.RecognitionConfig_AutoDecodingConfig{AutoDecodingConfig: &speechpb.AutoDetectDecodingConfig{}},
+		Model:          opts.Model,
+		LanguageCodes:  []string{opts.Language},
+		Features: &speechpb.RecognitionFeatures{
+			EnableWordTimeOffsets:      true,
+			EnableWordConfidence:       true,
+			MaxAlternatives:            int32(speechMaxAlternatives),
+			EnableAutomaticPunctuation: true,
+		},
+	}
+}
+
+// 既定のrecognizer（"_"）の名前
+func speechV2Recognizer(projectID string) string {
+	return fmt.Sprintf("projects/%s/locations/%s/recognizers/_", projectID, speechV2Location)
+}
+
+// 短い音声をv2で同期認識
+func recognizeShortV2(ctx context.Context, audioFile string, opts speechOptions) (string, []SubtitleSegment, error) {
+	client, projectID, err := speechV2Client()
+	if err != nil {
+		return "", nil, err
+	}
+	content, err := os.ReadFile(audioFile)
+	if err != nil {
+		return "", nil, fmt.Errorf("ファイル読み込みエラー: %v", err)
+	}
+
+	resp, err := client.Recognize(ctx, &speechpb.RecognizeRequest{
+		Recognizer:  speechV2Recognizer(projectID),
+		Config:      speechV2Config(opts),
+		AudioSource: &speechpb.RecognizeRequest_Content{Content: content},
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("音声認識エラー: %v", err)
+	}
+	text, segments := speechV2ResultsToSegments(resp.Results)
+	return text, segments, nil
+}
+
+// 長い音声をv2のバッチ認識（GCSにアップロードし、結果はレスポンスで受け取る）
+func recognizeLongV2(ctx context.Context, audioFile string, opts speechOptions) (string, []SubtitleSegment, error) {
+	client, projectID, err := speechV2Client()
+	if err != nil {
+		return "", nil, err
+	}
+	uri, cleanup, err := uploadSpeechAudio(ctx, audioFile)
+	if err != nil {
+		return "", nil, err
+	}
+	defer cleanup()
+
+	op, err := client.BatchRecognize(ctx, &speechpb.BatchRecognizeRequest{
+		Recognizer: speechV2Recognizer(projectID),
+		Config:     speechV2Config(opts),
+		Files: []*speechpb.BatchRecognizeFileMetadata{
+			{AudioSource: &speechpb.BatchRecognizeFileMetadata_Uri{Uri: uri}},
+		},
+		RecognitionOutputConfig: &speechpb.RecognitionOutputConfig{
+			Output: &speechpb.RecognitionOutputConfig_InlineResponseConfig{InlineResponseConfig: &speechpb.InlineOutputConfig{}},
+		},
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("音声認識開始エラー: %v", err)
+	}
+
+	// 処理完了を待機
+	resp, err := op.Wait(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("音声認識エラー: %v", err)
+	}
+	result, ok := resp.Results[uri]
+	if !ok {
+		return "", nil, fmt.Errorf("音声認識の結果がありません: %s", uri)
+	}
+	if result.Error != nil && result.Error.Code != 0 {
+		return "", nil, fmt.Errorf("音声認識エラー: %s", result.Error.Message)
+	}
+	text, segments := speechV2ResultsToSegments(result.GetInlineResult().GetTranscript().GetResults())
+	return text, segments, nil
+}
+
+// v2の認識結果をテキストとセグメントに変換（単語の時刻はStartOffset/EndOffset）
+func speechV2ResultsToSegments(results []*speechpb.SpeechRecognitionResult) (string, []SubtitleSegment) {
+	recognized := make([][]recognizedAlternative, len(results))
+	for i, result := range results {
+		for _, alt := range result.Alternatives {
+			words := make([]WordInfo, len(alt.Words))
+			for j, w := range alt.Words {
+				words[j] = WordInfo{
+					Word:       w.Word,
+					StartTime:  w.StartOffset.AsDuration().Seconds(),
+					EndTime:    w.EndOffset.AsDuration().Seconds(),
+					Confidence: float64(w.Confidence),
+				}
+			}
+			recognized[i] = append(recognized[i], recognizedAlternative{Transcript: alt.Transcript, Confidence: float64(alt.Confidence), Words: words})
+		}
+	}
+	return buildSpeechSegments(recognized)
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 字幕タイミングの検査ルール（環境変数で上書き可能）
+var (
+	subtitleMinDurationMs   = getEnvInt("SUBTITLE_MIN_DURATION_MS", 1000)   // 表示時間の下限
+	subtitleMaxDurationMs   = getEnvInt("SUBTITLE_MAX_DURATION_MS", 7000)   // 表示時間の上限
+	subtitleMinGapMs        = getEnvInt("SUBTITLE_MIN_GAP_MS", 80)          // セグメント間の最小の間隔（これ未満の隙間はちらつく）
+	subtitleMaxCPS          = getEnvFloat("SUBTITLE_MAX_CPS", 17)           // 1秒あたりの文字数の上限
+	subtitleMaxCPSJa        = getEnvFloat("SUBTITLE_MAX_CPS_JA", 4)         // 日本語の1秒あたりの文字数の上限
+	subtitleMaxLineLength   = getEnvInt("SUBTITLE_MAX_LINE_LENGTH", 42)     // 1行の文字数の上限
+	subtitleMaxLineLengthJa = getEnvInt("SUBTITLE_MAX_LINE_LENGTH_JA", 13)  // 日本語の1行の文字数の上限
+	subtitleMaxLines        = getEnvInt("SUBTITLE_MAX_LINES", 2)            // 1セグメントの行数の上限
+	subtitleAutoFix         = getEnv("SUBTITLE_AUTO_FIX", "true") == "true" // 処理の最後にタイミングを自動修正するか
+)
+
+// 検出する問題の種類
+const (
+	issueOverlap    = "overlap"     // 前のセグメントと重なっている
+	issueGap        = "gap"         // 前のセグメントとの隙間が短すぎる
+	issueTooShort   = "too_short"   // 表示時間が短すぎる
+	issueTooLong    = "too_long"    // 表示時間が長すぎる
+	issueCPS        = "cps"         // 読む速さ（1秒あたりの文字数）が速すぎる
+	issueLineLength = "line_length" // 行数・1行の文字数が多すぎる
+)
+
+// 検査ルール（時間は秒、クエリ・リクエストボディで項目ごとに上書き可能）
+type timingRules struct {
+	MinDuration   float64 `json:"min_duration" form:"min_duration"`
+	MaxDuration   float64 `json:"max_duration" form:"max_duration"` // 0は上限なし
+	MinGap        float64 `json:"min_gap" form:"min_gap"`
+	MaxCPS        float64 `json:"max_cps" form:"max_cps"`                 // 0は検査しない
+	MaxLineLength int     `json:"max_line_length" form:"max_line_length"` // 0は検査しない
+	MaxLines      int     `json:"max_lines" form:"max_lines"`
+	separator     string  // 結合するテキストの区切り（日本語は区切らない）
+}
+
+// 検出した問題
+type timingIssue struct {
+	Index   int     `json:"index"` // セグメント番号（1始まり）
+	Type    string  `json:"type"`
+	Message string  `json:"message"`
+	Value   float64 `json:"value"` // 実際の値（秒・文字/秒・行数など）
+	Limit   float64 `json:"limit"` // ルールの値
+}
+
+// 自動修正の内容
+type timingFix struct {
+	Action    string  `json:"action"`     // trim, chain, shift, extend, extend_start, merge
+	StartTime float64 `json:"start_time"` // 修正したセグメントの（修正前の）開始時間
+	Message   string  `json:"message"`
+}
+
+// 言語ごとの既定のルール
+func timingRulesFor(lang string) timingRules {
+	rules := timingRules{
+		MinDuration:   float64(subtitleMinDurationMs) / 1000,
+		MaxDuration:   float64(subtitleMaxDurationMs) / 1000,
+		MinGap:        float64(subtitleMinGapMs) / 1000,
+		MaxCPS:        subtitleMaxCPS,
+		MaxLineLength: subtitleMaxLineLength,
+		MaxLines:      subtitleMaxLines,
+		separator:     textSeparator(lang),
+	}
+	if baseLanguage(lang) == "ja" {
+		rules.MaxCPS = subtitleMaxCPSJa
+		rules.MaxLineLength = subtitleMaxLineLengthJa
+	}
+	return rules
+}
+
+// ルールを検証
+func (r timingRules) validate() error {
+	if r.MinDuration < 0 || r.MaxDuration < 0 || r.MinGap < 0 || r.MaxCPS < 0 || r.MaxLineLength < 0 || r.MaxLines < 0 {
+		return fmt.Errorf("ルールには0以上の値を指定してください")
+	}
+	if r.MaxDuration > 0 && r.MaxDuration < r.MinDuration {
+		return fmt.Errorf("max_durationはmin_duration以上で指定してください")
+	}
+	return nil
+}
+
+// セグメント一覧のタイミングを検査
+func validateTiming(segments []SubtitleSegment, rules timingRules) []timingIssue {
+	issues := []timingIssue{}
+	add := func(i int, typ string, value, limit float64, format string, args ...interface{}) {
+		issues = append(issues, timingIssue{Index: i + 1, Type: typ, Message: fmt.Sprintf(format, args...), Value: round3(value), Limit: round3(limit)})
+	}
+
+	for i, seg := range segments {
+		// 時間はミリ秒単位で比べる（浮動小数点の誤差で修正後の値が違反扱いにならないように）
+		if i > 0 {
+			gap := round3(seg.StartTime - segments[i-1].EndTime)
+			if gap < 0 {
+				add(i, issueOverlap, -gap, 0, "前のセグメントと%.3f秒重なっています", -gap)
+			} else if gap > 0 && gap < rules.MinGap {
+				add(i, issueGap, gap, rules.MinGap, "前のセグメントとの隙間（%.3f秒）が%.3f秒未満です", gap, rules.MinGap)
+			}
+		}
+
+		duration := round3(seg.EndTime - seg.StartTime)
+		if duration < rules.MinDuration {
+			add(i, issueTooShort, duration, rules.MinDuration, "表示時間（%.3f秒）が%.3f秒未満です", duration, rules.MinDuration)
+		}
+		if rules.MaxDuration > 0 && duration > rules.MaxDuration {
+			add(i, issueTooLong, duration, rules.MaxDuration, "表示時間（%.3f秒）が%.3f秒を超えています", duration, rules.MaxDuration)
+		}
+		if cps := charsPerSecond(seg); rules.MaxCPS > 0 && cps > rules.MaxCPS {
+			add(i, issueCPS, cps, rules.MaxCPS, "1秒あたりの文字数（%.1f）が%.1fを超えています", cps, rules.MaxCPS)
+		}
+		if rules.MaxLineLength > 0 {
+			if lines := wrapSubtitleText(seg.Text, rules.MaxLineLength); len(lines) > rules.MaxLines {
+				add(i, issueLineLength, float64(len(lines)), float64(rules.MaxLines), "1行%d文字で折り返すと%d行になり、%d行を超えています", rules.MaxLineLength, len(lines), rules.MaxLines)
+			}
+		}
+	}
+	return issues
+}
+
+// ルールの範囲内でタイミングを自動修正し、修正後のセグメントと修正内容を返す（修正した時間はミリ秒に丸める）
+// 重なり・短い隙間は前のセグメントの終了を縮めるか後ろのセグメントをずらし、
+// 短すぎる・速すぎるセグメントは前後の空きを使って延ばし、それでも短い場合は隣と結合する
+// テキストは変えないため、長すぎる・行が多すぎるセグメントは報告のみ
+func fixTiming(segments []SubtitleSegment, rules timingRules) ([]SubtitleSegment, []timingFix) {
+	fixed := make([]SubtitleSegment, len(segments))
+	copy(fixed, segments)
+	sort.SliceStable(fixed, func(i, j int) bool {
+		return fixed[i].StartTime < fixed[j].StartTime
+	})
+	fixes := []timingFix{}
+	record := func(action string, start float64, format string, args ...interface{}) {
+		fixes = append(fixes, timingFix{Action: action, StartTime: round3(start), Message: fmt.Sprintf(format, args...)})
+	}
+
+	// 1. 重なりと短い隙間
+	for i := 1; i < len(fixed); i++ {
+		prev, cur := &fixed[i-1], &fixed[i]
+		if cur.StartTime >= round3(prev.EndTime+rules.MinGap) || cur.StartTime == prev.EndTime {
+			continue
+		}
+
+		if end := round3(cur.StartTime - rules.MinGap); end-prev.StartTime >= rules.MinDuration {
+			record("trim", prev.StartTime, "終了時間を%.3f秒から%.3f秒に縮めました", prev.EndTime, end)
+			prev.EndTime = end
+			continue
+		}
+		if cur.StartTime > prev.EndTime {
+			// 隙間が短いだけなら、前のセグメントを次の開始までつなげる
+			record("chain", prev.StartTime, "終了時間を%.3f秒から次の開始（%.3f秒）まで延ばしました", prev.EndTime, cur.StartTime)
+			prev.EndTime = cur.StartTime
+			continue
+		}
+
+		start := round3(prev.EndTime + rules.MinGap)
+		if cur.EndTime-start >= rules.MinDuration {
+			record("shift", cur.StartTime, "開始時間を%.3f秒から%.3f秒にずらしました", cur.StartTime, start)
+			cur.StartTime = start
+			continue
+		}
+
+		// 縮めてもずらしても表示時間が足りない場合は結合
+		record("merge", cur.StartTime, "重なっている前のセグメントと結合しました")
+		*prev = mergeSegmentPair(*prev, *cur, rules.separator)
+		fixed = append(fixed[:i], fixed[i+1:]...)
+		i--
+	}
+
+	// 2. 表示時間と読む速さ
+	for i := 0; i < len(fixed); i++ {
+		seg := &fixed[i]
+		need := rules.MinDuration
+		if rules.MaxCPS > 0 {
+			need = math.Max(need, float64(subtitleCharCount(seg.Text))/rules.MaxCPS)
+		}
+		if rules.MaxDuration > 0 {
+			need = math.Min(need, rules.MaxDuration)
+		}
+		if round3(seg.EndTime-seg.StartTime) >= need {
+			continue
+		}
+
+		// 後ろの空きを使って終了を延ばす
+		end := seg.StartTime + need
+		if i+1 < len(fixed) {
+			end = math.Min(end, fixed[i+1].StartTime-rules.MinGap)
+		}
+		end = round3(end)
+		if end > seg.EndTime {
+			record("extend", seg.StartTime, "終了時間を%.3f秒から%.3f秒に延ばしました", seg.EndTime, end)
+			seg.EndTime = end
+		}
+
+		// 足りなければ前の空きを使って開始を早める
+		if round3(seg.EndTime-seg.StartTime) < need {
+			lower := 0.0
+			if i > 0 {
+				lower = fixed[i-1].EndTime + rules.MinGap
+			}
+			start := round3(math.Max(seg.EndTime-need, lower))
+			if start < seg.StartTime {
+				record("extend_start", seg.StartTime, "開始時間を%.3f秒から%.3f秒に早めました", seg.StartTime, start)
+				seg.StartTime = start
+			}
+		}
+
+		// それでも最短の表示時間に足りなければ、近い方の隣と結合（上限を超えない場合のみ）
+		if round3(seg.EndTime-seg.StartTime) >= rules.MinDuration {
+			continue
+		}
+		neighbor := -1
+		nextGap, prevGap := math.Inf(1), math.Inf(1)
+		if i+1 < len(fixed) {
+			nextGap = fixed[i+1].StartTime - seg.EndTime
+		}
+		if i > 0 {
+			prevGap = seg.StartTime - fixed[i-1].EndTime
+		}
+		if nextGap <= prevGap && i+1 < len(fixed) {
+			neighbor = i + 1
+		} else if i > 0 {
+			neighbor = i - 1
+		}
+		if neighbor < 0 {
+			continue
+		}
+		first, second := i, neighbor
+		if neighbor < i {
+			first, second = neighbor, i
+		}
+		if rules.MaxDuration > 0 && fixed[second].EndTime-fixed[first].StartTime > rules.MaxDuration {
+			continue
+		}
+		record("merge", seg.StartTime, "表示時間が短いため隣のセグメントと結合しました")
+		fixed[first] = mergeSegmentPair(fixed[first], fixed[second], rules.separator)
+		fixed = append(fixed[:second], fixed[second+1:]...)
+		// 結合したセグメントを検査し直す
+		i = first - 1
+	}
+	return fixed, fixes
+}
+
+// 隣り合う2つのセグメントを1つにまとめる
+func mergeSegmentPair(a, b SubtitleSegment, sep string) SubtitleSegment {
+	merged := SubtitleSegment{
+		StartTime: math.Min(a.StartTime, b.StartTime),
+		EndTime:   math.Max(a.EndTime, b.EndTime),
+		Text:      strings.TrimSpace(a.Text + sep + b.Text),
+	}
+	merged.Words, merged.Confidence = mergeConfidence(a, b)
+	merged.Alternatives = mergeAlternatives(a, b, sep)
+	return merged
+}
+
+// 1秒あたりの文字数
+func charsPerSecond(seg SubtitleSegment) float64 {
+	duration := seg.EndTime - seg.StartTime
+	if duration <= 0 {
+		return 0
+	}
+	return float64(subtitleCharCount(seg.Text)) / duration
+}
+
+// 読む文字数（改行は数えない）
+func subtitleCharCount(text string) int {
+	return utf8.RuneCountInString(strings.ReplaceAll(strings.TrimSpace(text), "\n", ""))
+}
+
+// テキストを1行の文字数で単語単位に折り返した行（改行済みの行はそれぞれ折り返す）
+func wrapSubtitleText(text string, width int) []string {
+	var lines []string
+	for _, para := range strings.Split(strings.TrimSpace(text), "\n") {
+		line := ""
+		for _, w := range strings.Fields(para) {
+			// 空白のない言語（日本語など）の長い語は文字数で区切る
+			for utf8.RuneCountInString(w) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				runes := []rune(w)
+				lines = append(lines, string(runes[:width]))
+				w = string(runes[width:])
+			}
+
+			switch {
+			case line == "":
+				line = w
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(w) > width:
+				lines = append(lines, line)
+				line = w
+			default:
+				line += " " + w
+			}
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// 小数点以下3桁（ミリ秒）に丸める
+// レスポンスの表示に加え、タイミングの検査・修正で浮動小数点の誤差を無視して比べるためにも使う
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// 処理の最後にタイミングを検査・自動修正（SUBTITLE_AUTO_FIX=falseなら検査のみ）
+func applyTimingQA(videoID, docType, lang string, segments []SubtitleSegment) ([]SubtitleSegment, bool) {
+	rules := timingRulesFor(lang)
+	changed := false
+	if subtitleAutoFix {
+		var fixes []timingFix
+		segments, fixes = fixTiming(segments, rules)
+		changed = len(fixes) > 0
+		if changed {
+			log.Printf("タイミング自動修正: VideoID=%s, %s, 修正%d件", videoID, docType, len(fixes))
+		}
+	}
+	if issues := validateTiming(segments, rules); len(issues) > 0 {
+		log.Printf("タイミング検査: VideoID=%s, %s, 問題%d件", videoID, docType, len(issues))
+	}
+	return segments, changed
+}
+
+// 編集対象ドキュメントのセグメントと言語を取得（呼び出し側でmuをロック済み）
+func documentSegments(docType, videoID string) ([]SubtitleSegment, string, bool) {
+	switch docType {
+	case docTranscript:
+		for _, transcript := range transcripts {
+			if transcript.VideoId == videoID {
+				return transcript.Segments, transcript.Language, true
+			}
+		}
+	case docTranslation:
+		if i := findTranslationIndexByVideo(videoID); i >= 0 {
+			return translations[i].Segments, translations[i].TargetLang, true
+		}
+	}
+	return nil, "", false
+}
+
+// ドキュメントのセグメントを置き換え、更新後のドキュメントを返す（呼び出し側でmuをロック済み）
+func setDocumentSegments(docType, docID string, segments []SubtitleSegment) interface{} {
+	switch docType {
+	case docTranscript:
+		for i := range transcripts {
+			if transcripts[i].ID == docID {
+				transcripts[i].Segments = segments
+				transcripts[i].TransriptSrt = joinSegmentTexts(segments, " ")
+				return transcripts[i]
+			}
+		}
+	case docTranslation:
+		for i := range translations {
+			if translations[i].ID == docID {
+				translations[i].Segments = segments
+				translations[i].TranslatedSrt = joinSegmentTexts(segments, "\n")
+				return translations[i]
+			}
+		}
+	}
+	return nil
+}
+
+// GET /videos/:id/{transcript,translation}/qa - タイミングの検査
+// クエリでルールを上書き可能（min_duration, max_duration, min_gap, max_cps, max_line_length, max_lines）
+func timingReport(docType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		segments, lang, ok := documentSegments(docType, c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+
+		rules := timingRulesFor(lang)
+		if err := c.ShouldBindQuery(&rules); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "reason": "invalid_timing_rules"})
+			return
+		}
+		if err := rules.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "reason": "invalid_timing_rules"})
+			return
+		}
+
+		issues := validateTiming(segments, rules)
+		c.JSON(http.StatusOK, gin.H{
+			"rules":          rules,
+			"total_segments": len(segments),
+			"issue_count":    len(issues),
+			"issues":         issues,
+		})
+	}
+}
+
+// POST /videos/:id/{transcript,translation}/qa/fix - タイミングの自動修正
+// リクエストボディでルールを上書き可能（省略時は既定のルール）、修正した場合はリビジョンを記録
+func fixTimingHandler(docType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		videoID := c.Param("id")
+		docID, ok := resolveDocumentID(docType, videoID)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+		segments, lang, _ := documentSegments(docType, videoID)
+
+		rules := timingRulesFor(lang)
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&rules); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "reason": "invalid_timing_rules"})
+				return
+			}
+		}
+		if err := rules.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "reason": "invalid_timing_rules"})
+			return
+		}
+
+		fixed, fixes := fixTiming(segments, rules)
+		resp := gin.H{
+			"rules":  rules,
+			"fixes":  fixes,
+			"issues": validateTiming(fixed, rules),
+		}
+		if len(fixes) > 0 {
+			resp["document"] = setDocumentSegments(docType, docID, fixed)
+			resp["revision"] = recordRevision(docType, docID, videoID, requestAuthor(c), "timing_fix", fixed).Number
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestFixTiming(t *testing.T) {
+	rules := timingRules{MinDuration: 1, MaxDuration: 7, MinGap: 0.1, MaxLines: 2, separator: " "}
+
+	tests := []struct {
+		name        string
+		rules       timingRules
+		segments    []SubtitleSegment
+		want        []SubtitleSegment
+		wantActions []string
+	}{
+		{
+			name:        "no issues",
+			rules:       rules,
+			segments:    []SubtitleSegment{{StartTime: 0, EndTime: 2, Text: "a"}, {StartTime: 3, EndTime: 5, Text: "b"}},
+			want:        []SubtitleSegment{{StartTime: 0, EndTime: 2, Text: "a"}, {StartTime: 3, EndTime: 5, Text: "b"}},
+			wantActions: []string{},
+		},
+		{
+			name:        "overlap trims previous end",
+			rules:       rules,
+			segments:    []SubtitleSegment{{StartTime: 0, EndTime: 3, Text: "a"}, {StartTime: 2.5, EndTime: 4, Text: "b"}},
+			want:        []SubtitleSegment{{StartTime: 0, EndTime: 2.4, Text: "a"}, {StartTime: 2.5, EndTime: 4, Text: "b"}},
+			wantActions: []string{"trim"},
+		},
+		{
+			name:        "short gap chains previous to next start",
+			rules:       rules,
+			segments:    []SubtitleSegment{{StartTime: 0, EndTime: 1.02, Text: "a"}, {StartTime: 1.05, EndTime: 3, Text: "b"}},
+			want:        []SubtitleSegment{{StartTime: 0, EndTime: 1.05, Text: "a"}, {StartTime: 1.05, EndTime: 3, Text: "b"}},
+			wantActions: []string{"chain"},
+		},
+		{
+			name:        "overlap shifts next start when previous cannot shrink",
+			rules:       rules,
+			segments:    []SubtitleSegment{{StartTime: 0, EndTime: 1.5, Text: "a"}, {StartTime: 0.8, EndTime: 3, Text: "b"}},
+			want:        []SubtitleSegment{{StartTime: 0, EndTime: 1.5, Text: "a"}, {StartTime: 1.6, EndTime: 3, Text: "b"}},
+			wantActions: []string{"shift"},
+		},
+		{
+			name:        "overlap merges when neither trim nor shift fits",
+			rules:       rules,
+			segments:    []SubtitleSegment{{StartTime: 0, EndTime: 1.5, Text: "a"}, {StartTime: 0.8, EndTime: 2, Text: "b"}},
+			want:        []SubtitleSegment{{StartTime: 0, EndTime: 2, Text: "a b"}},
+			wantActions: []string{"merge"},
+		},
+		{
+			name:        "japanese merge has no separator",
+			rules:       timingRules{MinDuration: 1, MaxDuration: 7, MinGap: 0.1, MaxLines: 2, separator: textSeparator("ja")},
+			segments:    []SubtitleSegment{{StartTime: 0, EndTime: 1.5, Text: "こんにちは"}, {StartTime: 0.8, EndTime: 2, Text: "世界"}},
+			want:        []SubtitleSegment{{StartTime: 0, EndTime: 2, Text: "こんにちは世界"}},
+			wantActions: []string{"merge"},
+		},
+		{
+			name:        "short segment extends into free space",
+			rules:       rules,
+			segments:    []SubtitleSegment{{StartTime: 0, EndTime: 0.5, Text: "a"}, {StartTime: 3, EndTime: 5, Text: "b"}},
+			want:        []SubtitleSegment{{StartTime: 0, EndTime: 1, Text: "a"}, {StartTime: 3, EndTime: 5, Text: "b"}},
+			wantActions: []string{"extend"},
+		},
+		{
+			name:  "short segment extends end then start",
+			rules: rules,
+			segments: []SubtitleSegment{
+				{StartTime: 0, EndTime: 1.5, Text: "a"},
+				{StartTime: 2.6, EndTime: 3, Text: "b"},
+				{StartTime: 3.2, EndTime: 5, Text: "c"},
+			},
+			want: []SubtitleSegment{
+				{StartTime: 0, EndTime: 1.5, Text: "a"},
+				{StartTime: 2.1, EndTime: 3.1, Text: "b"},
+				{StartTime: 3.2, EndTime: 5, Text: "c"},
+			},
+			wantActions: []string{"extend", "extend_start"},
+		},
+		{
+			name:        "short segment without room merges with nearest neighbour",
+			rules:       rules,
+			segments:    []SubtitleSegment{{StartTime: 0, EndTime: 0.3, Text: "a"}, {StartTime: 0.4, EndTime: 3, Text: "b"}},
+			want:        []SubtitleSegment{{StartTime: 0, EndTime: 3, Text: "a b"}},
+			wantActions: []string{"merge"},
+		},
+		{
+			name:        "merge is skipped when it would exceed max duration",
+			rules:       timingRules{MinDuration: 1, MaxDuration: 2, MinGap: 0.1, MaxLines: 2, separator: " "},
+			segments:    []SubtitleSegment{{StartTime: 0, EndTime: 0.3, Text: "a"}, {StartTime: 0.4, EndTime: 2.4, Text: "b"}},
+			want:        []SubtitleSegment{{StartTime: 0, EndTime: 0.3, Text: "a"}, {StartTime: 0.4, EndTime: 2.4, Text: "b"}},
+			wantActions: []string{},
+		},
+		{
+			name:        "cps extends reading time",
+			rules:       timingRules{MinDuration: 1, MaxDuration: 7, MinGap: 0.1, MaxCPS: 10, MaxLines: 2, separator: " "},
+			segments:    []SubtitleSegment{{StartTime: 0, EndTime: 1, Text: "twenty characters!!!"}},
+			want:        []SubtitleSegment{{StartTime: 0, EndTime: 2, Text: "twenty characters!!!"}},
+			wantActions: []string{"extend"},
+		},
+		{
+			name:        "unsorted input is sorted first",
+			rules:       rules,
+			segments:    []SubtitleSegment{{StartTime: 3, EndTime: 5, Text: "b"}, {StartTime: 0, EndTime: 2, Text: "a"}},
+			want:        []SubtitleSegment{{StartTime: 0, EndTime: 2, Text: "a"}, {StartTime: 3, EndTime: 5, Text: "b"}},
+			wantActions: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := append([]SubtitleSegment(nil), tt.segments...)
+			got, fixes := fixTiming(tt.segments, tt.rules)
+
+			if !reflect.DeepEqual(tt.segments, input) {
+				t.Errorf("input was modified: %+v", tt.segments)
+			}
+			actions := []string{}
+			for _, f := range fixes {
+				actions = append(actions, f.Action)
+			}
+			if !reflect.DeepEqual(actions, tt.wantActions) {
+				t.Errorf("actions = %v, want %v", actions, tt.wantActions)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("segments = %+v, want %+v", got, tt.want)
+			}
+			for i := range tt.want {
+				if math.Abs(got[i].StartTime-tt.want[i].StartTime) > 1e-9 || math.Abs(got[i].EndTime-tt.want[i].EndTime) > 1e-9 || got[i].Text != tt.want[i].Text {
+					t.Errorf("segment %d = %+v, want %+v", i+1, got[i], tt.want[i])
+				}
+			}
+
+			// 修正後は重なり・隙間の問題が残らない（結合できなかった場合を除く）
+			for _, issue := range validateTiming(got, tt.rules) {
+				if issue.Type == issueOverlap || issue.Type == issueGap {
+					t.Errorf("issue remains after fix: %+v", issue)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateTiming(t *testing.T) {
+	rules := timingRules{MinDuration: 1, MaxDuration: 7, MinGap: 0.1, MaxCPS: 10, MaxLineLength: 10, MaxLines: 1}
+	segments := []SubtitleSegment{
+		{StartTime: 0, EndTime: 2, Text: "ok"},
+		{StartTime: 1.5, EndTime: 2.3, Text: "hi"},
+		{StartTime: 2.35, EndTime: 10, Text: "short one"},
+		{StartTime: 11, EndTime: 12, Text: "much too long text"},
+	}
+
+	var got []string
+	for _, issue := range validateTiming(segments, rules) {
+		got = append(got, issue.Type)
+	}
+	want := []string{issueOverlap, issueTooShort, issueGap, issueTooLong, issueCPS, issueLineLength}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("issues = %v, want %v", got, want)
+	}
+}
+
+func TestMergeSegmentPair(t *testing.T) {
+	a := SubtitleSegment{StartTime: 0, EndTime: 1, Text: "hello", Confidence: 0.9, Alternatives: []SegmentAlternative{{Text: "yellow", Confidence: 0.5}}}
+	b := SubtitleSegment{StartTime: 1, EndTime: 2, Text: "world", Confidence: 0.7, Alternatives: []SegmentAlternative{{Text: "word", Confidence: 0.8}}}
+
+	got := mergeSegmentPair(a, b, " ")
+	want := SubtitleSegment{
+		StartTime:  0,
+		EndTime:    2,
+		Text:       "hello world",
+		Confidence: 0.7,
+		Alternatives: []SegmentAlternative{
+			{Text: "yellow world", Confidence: 0.5},
+			{Text: "hello word", Confidence: 0.8},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged = %+v, want %+v", got, want)
+	}
+}
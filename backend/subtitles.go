@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 字幕ファイル形式
+const (
+	formatSRT = "srt"
+	formatVTT = "vtt"
+)
+
+// タイムスタンプ（HH:MM:SS,mmm / HH:MM:SS.mmm / MM:SS.mmm）
+var subtitleTimePattern = regexp.MustCompile(`^(?:(\d+):)?(\d{1,2}):(\d{1,2})(?:[.,](\d{1,3}))?$`)
+
+// 字幕テキストから除去するタグ（HTML風タグ・VTTのクラス/話者/時刻タグ・ASSの{\...}タグ）
+var (
+	subtitleTagPattern = regexp.MustCompile(`</?[a-zA-Z][^>]*>|<\d{1,2}:\d{2}[:.\d]*>|</?c[.\w]*>`)
+	assTagPattern      = regexp.MustCompile(`\{\\[^}]*\}`)
+)
+
+// HTMLエンティティの置換
+var subtitleEntityReplacer = strings.NewReplacer(
+	"&amp;", "&",
+	"&lt;", "<",
+	"&gt;", ">",
+	"&nbsp;", " ",
+	"&quot;", `"`,
+	"&#39;", "'",
+	"&lrm;", "",
+	"&rlm;", "",
+)
+
+// SRT/WebVTTファイルを解析してセグメントに変換
+// BOM・CRLF・番号の欠落や不正・スタイルタグを許容する
+func parseSubtitleFile(data []byte) ([]SubtitleSegment, string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	format := formatSRT
+	if strings.HasPrefix(strings.TrimSpace(text), "WEBVTT") {
+		format = formatVTT
+	}
+
+	var segments []SubtitleSegment
+	for _, block := range splitSubtitleBlocks(text) {
+		lines := strings.Split(block, "\n")
+
+		// VTTのヘッダー・コメント・スタイル定義は読み飛ばす
+		first := strings.TrimSpace(lines[0])
+		if strings.HasPrefix(first, "WEBVTT") || strings.HasPrefix(first, "NOTE") ||
+			strings.HasPrefix(first, "STYLE") || strings.HasPrefix(first, "REGION") {
+			continue
+		}
+
+		// "-->"を含む行をタイミング行とみなす（その前の番号・キューIDは無視）
+		timingLine := -1
+		for i, line := range lines {
+			if strings.Contains(line, "-->") {
+				timingLine = i
+				break
+			}
+		}
+		if timingLine < 0 {
+			continue
+		}
+
+		start, end, err := parseTimingLine(lines[timingLine])
+		if err != nil {
+			return nil, format, fmt.Errorf("タイミング行の解析エラー（%q）: %v", lines[timingLine], err)
+		}
+
+		cueText := cleanSubtitleText(lines[timingLine+1:])
+		if cueText == "" {
+			continue
+		}
+
+		segments = append(segments, SubtitleSegment{
+			StartTime: start,
+			EndTime:   end,
+			Text:      cueText,
+		})
+	}
+
+	if len(segments) == 0 {
+		return nil, format, fmt.Errorf("字幕が1件も見つかりませんでした")
+	}
+
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].StartTime < segments[j].StartTime
+	})
+	return segments, format, nil
+}
+
+// 空行で区切られたブロックに分割
+func splitSubtitleBlocks(text string) []string {
+	var blocks []string
+	var current []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			if len(current) > 0 {
+				blocks = append(blocks, strings.Join(current, "\n"))
+				current = nil
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		blocks = append(blocks, strings.Join(current, "\n"))
+	}
+	return blocks
+}
+
+// "00:00:01,000 --> 00:00:02,500 align:start" 形式のタイミング行を解析
+func parseTimingLine(line string) (float64, float64, error) {
+	parts := strings.SplitN(line, "-->", 2)
+	start, err := parseSubtitleTimestamp(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// 終了時刻の後ろにあるVTTのキュー設定を除外
+	endFields := strings.Fields(parts[1])
+	if len(endFields) == 0 {
+		return 0, 0, fmt.Errorf("終了時刻がありません")
+	}
+	end, err := parseSubtitleTimestamp(endFields[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("終了時刻が開始時刻より前です")
+	}
+	return start, end, nil
+}
+
+// タイムスタンプを秒に変換
+func parseSubtitleTimestamp(ts string) (float64, error) {
+	m := subtitleTimePattern.FindStringSubmatch(ts)
+	if m == nil {
+		return 0, fmt.Errorf("不正なタイムスタンプ: %s", ts)
+	}
+
+	hours, _ := strconv.Atoi(m[1])
+	minutes, _ := strconv.Atoi(m[2])
+	seconds, _ := strconv.Atoi(m[3])
+
+	millis := 0
+	if m[4] != "" {
+		// "5" → 500ms, "05" → 50ms のように桁数で補正
+		frac := m[4] + strings.Repeat("0", 3-len(m[4]))
+		millis, _ = strconv.Atoi(frac)
+	}
+
+	return float64(hours*3600+minutes*60+seconds) + float64(millis)/1000, nil
+}
+
+// キューのテキスト行からタグを除去して1行にまとめる
+func cleanSubtitleText(lines []string) string {
+	var parts []string
+	for _, line := range lines {
+		line = subtitleTagPattern.ReplaceAllString(line, "")
+		line = assTagPattern.ReplaceAllString(line, "")
+		line = subtitleEntityReplacer.Replace(line)
+		line = strings.TrimSpace(line)
+		if line != "" {
+			parts = append(parts, line)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// セグメントをSRT形式の文字列に変換
+func renderSRT(segments []SubtitleSegment) string {
+	var b strings.Builder
+	for i, seg := range segments {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, formatSRTTimestamp(seg.StartTime), formatSRTTimestamp(seg.EndTime), seg.Text)
+	}
+	return b.String()
+}
+
+// 秒をSRTのタイムスタンプ（HH:MM:SS,mmm）に変換
+func formatSRTTimestamp(seconds float64) string {
+	if seconds < 0 {
+		seconds = 0
+	}
+	total := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", total/3600000, total/60000%60, total/1000%60, total%1000)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseSubtitleFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantFormat string
+		want       []SubtitleSegment
+	}{
+		{
+			name:       "basic srt",
+			input:      "1\n00:00:01,000 --> 00:00:02,500\nHello\n\n2\n00:00:03,000 --> 00:00:04,000\nWorld\n",
+			wantFormat: formatSRT,
+			want: []SubtitleSegment{
+				{StartTime: 1, EndTime: 2.5, Text: "Hello"},
+				{StartTime: 3, EndTime: 4, Text: "World"},
+			},
+		},
+		{
+			name:       "bom and crlf",
+			input:      "\xef\xbb\xbf1\r\n00:00:01,000 --> 00:00:02,000\r\nHello\r\n\r\n2\r\n00:00:02,000 --> 00:00:03,000\r\nWorld\r\n",
+			wantFormat: formatSRT,
+			want: []SubtitleSegment{
+				{StartTime: 1, EndTime: 2, Text: "Hello"},
+				{StartTime: 2, EndTime: 3, Text: "World"},
+			},
+		},
+		{
+			name:       "bare cr line endings",
+			input:      "1\r00:00:01,000 --> 00:00:02,000\rHello\r\r",
+			wantFormat: formatSRT,
+			want:       []SubtitleSegment{{StartTime: 1, EndTime: 2, Text: "Hello"}},
+		},
+		{
+			name:       "missing and wrong cue numbers",
+			input:      "00:00:01,000 --> 00:00:02,000\nNo number\n\n7\n00:00:03,000 --> 00:00:04,000\nWrong number\n\nabc\n00:00:05,000 --> 00:00:06,000\nNot a number\n",
+			wantFormat: formatSRT,
+			want: []SubtitleSegment{
+				{StartTime: 1, EndTime: 2, Text: "No number"},
+				{StartTime: 3, EndTime: 4, Text: "Wrong number"},
+				{StartTime: 5, EndTime: 6, Text: "Not a number"},
+			},
+		},
+		{
+			name:       "multi-line cue is joined",
+			input:      "1\n00:00:01,000 --> 00:00:02,000\nfirst line\nsecond line\n",
+			wantFormat: formatSRT,
+			want:       []SubtitleSegment{{StartTime: 1, EndTime: 2, Text: "first line second line"}},
+		},
+		{
+			name:       "html and ass styling tags",
+			input:      "1\n00:00:01,000 --> 00:00:02,000\n<i>Hello</i> <font color=\"#fff\">there</font>\n\n2\n00:00:03,000 --> 00:00:04,000\n{\\an8}{\\i1}Top{\\i0}\n",
+			wantFormat: formatSRT,
+			want: []SubtitleSegment{
+				{StartTime: 1, EndTime: 2, Text: "Hello there"},
+				{StartTime: 3, EndTime: 4, Text: "Top"},
+			},
+		},
+		{
+			name:       "html entities",
+			input:      "1\n00:00:01,000 --> 00:00:02,000\nTom &amp; Jerry &lt;3&nbsp;&quot;hi&quot;\n",
+			wantFormat: formatSRT,
+			want:       []SubtitleSegment{{StartTime: 1, EndTime: 2, Text: `Tom & Jerry <3 "hi"`}},
+		},
+		{
+			name:       "tag-only cue is skipped",
+			input:      "1\n00:00:01,000 --> 00:00:02,000\n<i></i>\n\n2\n00:00:03,000 --> 00:00:04,000\nKept\n",
+			wantFormat: formatSRT,
+			want:       []SubtitleSegment{{StartTime: 3, EndTime: 4, Text: "Kept"}},
+		},
+		{
+			name: "webvtt with header, note, style, cue ids and settings",
+			input: "WEBVTT - title\nKind: captions\n\nNOTE a comment\nspanning lines\n\nSTYLE\n::cue { color: red }\n\n" +
+				"intro\n00:01.000 --> 00:02.500 align:start position:10%\n<v Roger>Hello</v> <c.yellow>world</c>\n\n" +
+				"00:00:03.000 --> 00:00:04.000\nkaraoke <00:00:03.500>style\n",
+			wantFormat: formatVTT,
+			want: []SubtitleSegment{
+				{StartTime: 1, EndTime: 2.5, Text: "Hello world"},
+				{StartTime: 3, EndTime: 4, Text: "karaoke style"},
+			},
+		},
+		{
+			name:       "out of order cues are sorted",
+			input:      "2\n00:00:05,000 --> 00:00:06,000\nLater\n\n1\n00:00:01,000 --> 00:00:02,000\nEarlier\n",
+			wantFormat: formatSRT,
+			want: []SubtitleSegment{
+				{StartTime: 1, EndTime: 2, Text: "Earlier"},
+				{StartTime: 5, EndTime: 6, Text: "Later"},
+			},
+		},
+		{
+			name:       "extra blank lines between cues",
+			input:      "\n\n1\n00:00:01,000 --> 00:00:02,000\nHello\n\n\n\n2\n00:00:03,000 --> 00:00:04,000\nWorld\n\n\n",
+			wantFormat: formatSRT,
+			want: []SubtitleSegment{
+				{StartTime: 1, EndTime: 2, Text: "Hello"},
+				{StartTime: 3, EndTime: 4, Text: "World"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, format, err := parseSubtitleFile([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if format != tt.wantFormat {
+				t.Errorf("format = %q, want %q", format, tt.wantFormat)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("segments = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSubtitleFileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"no cues", "WEBVTT\n\nNOTE nothing here\n"},
+		{"end before start", "1\n00:00:02,000 --> 00:00:01,000\nBackwards\n"},
+		{"bad timestamp", "1\n00:00:xx,000 --> 00:00:01,000\nBroken\n"},
+		{"missing end", "1\n00:00:01,000 -->\nBroken\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := parseSubtitleFile([]byte(tt.input)); err == nil {
+				t.Errorf("expected error for %q", tt.input)
+			}
+		})
+	}
+}
+
+func TestParseSubtitleTimestamp(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"00:00:01,000", 1},
+		{"00:00:01.000", 1},
+		{"01:02:03,456", 3723.456},
+		{"123:00:00,000", 442800},
+		{"02:03.456", 123.456},
+		{"2:03", 123},
+		{"00:00:01,5", 1.5},
+		{"00:00:01,05", 1.05},
+		{"00:00:01,005", 1.005},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseSubtitleTimestamp(tt.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("parseSubtitleTimestamp(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []string{"", "1", "aa:bb:cc", "00:00:01,0000", "00:00:01;000"} {
+		if _, err := parseSubtitleTimestamp(bad); err == nil {
+			t.Errorf("parseSubtitleTimestamp(%q) expected error", bad)
+		}
+	}
+}
+
+func TestFormatSRTTimestamp(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+	}{
+		{0, "00:00:00,000"},
+		{-1, "00:00:00,000"},
+		{1.5, "00:00:01,500"},
+		{3723.456, "01:02:03,456"},
+		{59.9996, "00:01:00,000"},
+	}
+
+	for _, tt := range tests {
+		if got := formatSRTTimestamp(tt.in); got != tt.want {
+			t.Errorf("formatSRTTimestamp(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRenderSRTRoundTrip(t *testing.T) {
+	segments := []SubtitleSegment{
+		{StartTime: 0.5, EndTime: 1.25, Text: "Hello"},
+		{StartTime: 3661.001, EndTime: 3662, Text: "World"},
+	}
+
+	srt := renderSRT(segments)
+	want := "1\n00:00:00,500 --> 00:00:01,250\nHello\n\n2\n01:01:01,001 --> 01:01:02,000\nWorld\n\n"
+	if srt != want {
+		t.Fatalf("renderSRT = %q, want %q", srt, want)
+	}
+
+	got, _, err := parseSubtitleFile([]byte(srt))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != len(segments) {
+		t.Fatalf("got %d segments, want %d", len(got), len(segments))
+	}
+	for i := range segments {
+		if math.Abs(got[i].StartTime-segments[i].StartTime) > 1e-9 || math.Abs(got[i].EndTime-segments[i].EndTime) > 1e-9 || got[i].Text != segments[i].Text {
+			t.Errorf("segment %d = %+v, want %+v", i+1, got[i], segments[i])
+		}
+	}
+}
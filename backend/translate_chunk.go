@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// チャンク翻訳の設定（環境変数で上書き可能）
+var (
+	chunkMaxTokens   = getEnvInt("TRANSLATION_CHUNK_TOKENS", 2000)  // 1チャンクあたりの最大トークン数（推定）
+	chunkContextSize = getEnvInt("TRANSLATION_CONTEXT_SEGMENTS", 3) // 文脈として渡す前チャンクのセグメント数
+	chunkConcurrency = getEnvInt("TRANSLATION_CONCURRENCY", 3)      // 同時に送信するチャンク数
+
+	chunkValidationRetries = getEnvInt("TRANSLATION_VALIDATION_RETRIES", 2) // 応答が検証に失敗した場合の再リクエスト回数
+
+	translationTargetLang = getEnv("TRANSLATION_TARGET_LANG", "ja") // 翻訳先の言語
+)
+
+// プロンプトで使う言語名（表にない言語はコードのまま渡す）
+var languageNames = map[string]string{
+	"en": "English",
+	"ja": "Japanese",
+	"zh": "Chinese",
+	"ko": "Korean",
+	"es": "Spanish",
+	"fr": "French",
+	"de": "German",
+	"pt": "Portuguese",
+	"it": "Italian",
+	"ru": "Russian",
+}
+
+// 言語コードから地域を除いた基本の言語（例: en-US → en）
+func baseLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// プロンプト用の言語名
+func languageName(lang string) string {
+	if name, ok := languageNames[baseLanguage(lang)]; ok {
+		return name
+	}
+	return lang
+}
+
+// 翻訳用チャンク（セグメント境界で分割）
+type translationChunk struct {
+	Index    int               // チャンク番号
+	Indices  []int             // 元セグメント配列での位置
+	Segments []SubtitleSegment // 翻訳対象のセグメント
+	Context  []SubtitleSegment // 直前のセグメント（文脈用、翻訳対象外）
+}
+
+// 翻訳応答の1要素（構造化出力）
+type translatedLine struct {
+	Index int    `json:"index"`
+	Text  string `json:"text"`
+}
+
+// 翻訳応答のレスポンススキーマ（{index, text}の配列）
+var translatedLinesSchema = &geminiSchema{
+	Type: "ARRAY",
+	Items: &geminiSchema{
+		Type: "OBJECT",
+		Properties: map[string]*geminiSchema{
+			"index": {Type: "INTEGER"},
+			"text":  {Type: "STRING"},
+		},
+		Required: []string{"index", "text"},
+	},
+}
+
+// テキストのトークン数を推定（英語は約4文字で1トークン、CJKは1文字1トークン）
+func estimateTokens(text string) int {
+	latin := 0
+	tokens := 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			tokens++
+		} else {
+			latin++
+		}
+	}
+	return tokens + (latin+3)/4
+}
+
+// 翻訳対象のセグメント（pendingで位置を指定）を推定トークン数に基づいてチャンクに分割
+func splitIntoChunks(segments []SubtitleSegment, pending []int, maxTokens, contextSize int) []translationChunk {
+	var chunks []translationChunk
+	start := 0
+	tokens := 0
+
+	flush := func(end int) {
+		if end <= start {
+			return
+		}
+		indices := pending[start:end]
+		chunk := translationChunk{Index: len(chunks), Indices: indices}
+		for _, idx := range indices {
+			chunk.Segments = append(chunk.Segments, segments[idx])
+		}
+		// 文脈は元の時系列で直前のセグメントから取る
+		ctxStart := indices[0] - contextSize
+		if ctxStart < 0 {
+			ctxStart = 0
+		}
+		chunk.Context = segments[ctxStart:indices[0]]
+		chunks = append(chunks, chunk)
+		start = end
+		tokens = 0
+	}
+
+	for i, idx := range pending {
+		t := estimateTokens(segments[idx].Text)
+		// 上限を超える場合は現在のチャンクを確定（1セグメントのみの場合はそのまま含める）
+		if tokens > 0 && tokens+t > maxTokens {
+			flush(i)
+		}
+		tokens += t
+	}
+	flush(len(pending))
+
+	return chunks
+}
+
+// チャンク翻訳用のプロンプトを作成
+func buildChunkPrompt(chunk translationChunk, sourceLang, targetLang string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "You are a professional subtitle translator. Translate each numbered line from %s to %s.\n", languageName(sourceLang), languageName(targetLang))
+	b.WriteString("Respond with a JSON array containing exactly one object {\"index\": number, \"text\": translation} per input line, using the same index numbers. Do not merge or split lines.\n")
+
+	if len(chunk.Context) > 0 {
+		b.WriteString("\nPrevious lines for context only (do NOT translate these):\n")
+		for _, seg := range chunk.Context {
+			b.WriteString(seg.Text)
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("\nLines to translate:\n")
+	for i, seg := range chunk.Segments {
+		fmt.Fprintf(&b, "[%d] %s\n", chunk.Indices[i]+1, strings.ReplaceAll(seg.Text, "\n", " "))
+	}
+	return b.String()
+}
+
+// 応答JSONを修復（コードフェンスや前後の余計な文字を除去）
+func repairJSONArray(text string) string {
+	text = strings.TrimSpace(text)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+
+	start := strings.Index(text, "[")
+	end := strings.LastIndex(text, "]")
+	if start >= 0 && end > start {
+		return text[start : end+1]
+	}
+	return strings.TrimSpace(text)
+}
+
+// 応答JSONを解析し、チャンクのセグメント番号と照合
+func parseTranslatedLines(text string, indices []int) ([]string, error) {
+	var items []translatedLine
+	if err := json.Unmarshal([]byte(text), &items); err != nil {
+		if err := json.Unmarshal([]byte(repairJSONArray(text)), &items); err != nil {
+			return nil, fmt.Errorf("翻訳結果のJSON解析エラー: %v", err)
+		}
+	}
+
+	position := make(map[int]int, len(indices))
+	for i, idx := range indices {
+		position[idx+1] = i
+	}
+
+	lines := make([]string, len(indices))
+	found := make([]bool, len(indices))
+	for _, item := range items {
+		idx, ok := position[item.Index]
+		if !ok {
+			return nil, fmt.Errorf("翻訳結果の番号[%d]が範囲外です", item.Index)
+		}
+		if found[idx] {
+			return nil, fmt.Errorf("翻訳結果の番号[%d]が重複しています", item.Index)
+		}
+		lines[idx] = strings.TrimSpace(item.Text)
+		found[idx] = true
+	}
+
+	for i, ok := range found {
+		if !ok {
+			return nil, fmt.Errorf("翻訳結果に行[%d]がありません", indices[i]+1)
+		}
+	}
+	return lines, nil
+}
+
+// チャンクを1件翻訳（検証に失敗した応答は再リクエスト）
+func translateChunk(ctx context.Context, chunk translationChunk, sourceLang, targetLang, apiKey string) ([]string, error) {
+	prompt := buildChunkPrompt(chunk, sourceLang, targetLang)
+
+	var lastErr error
+	for attempt := 0; attempt <= chunkValidationRetries; attempt++ {
+		content, err := callGeminiJSON(ctx, prompt, translatedLinesSchema, apiKey)
+		if err != nil {
+			return nil, err
+		}
+
+		lines, err := parseTranslatedLines(content, chunk.Indices)
+		if err == nil {
+			return lines, nil
+		}
+		lastErr = err
+		log.Printf("翻訳結果の検証エラー（チャンク%d, %d回目）: %v", chunk.Index, attempt+1, err)
+	}
+	return nil, lastErr
+}
+
+// セグメント単位でチャンク分割して並列翻訳する関数（キャッシュ済みのセグメントは再翻訳しない）
+func translateSegmentsWithGPT(ctx context.Context, segments []SubtitleSegment, sourceLang, targetLang, apiKey string) ([]SubtitleSegment, error) {
+	if len(segments) == 0 {
+		return nil, nil
+	}
+
+	// キャッシュを確認し、未翻訳のセグメントだけを抽出（同じ原文は1回だけ翻訳）
+	texts := make([]string, len(segments))
+	filled := make([]bool, len(segments))
+	keys := make([]string, len(segments))
+	firstByKey := map[string]int{}
+	var pending []int
+	for i, seg := range segments {
+		keys[i] = translationCacheKey(seg.Text, baseLanguage(sourceLang), baseLanguage(targetLang), geminiModel)
+		if text, ok := segmentCache.Get(keys[i]); ok {
+			texts[i] = text
+			filled[i] = true
+			continue
+		}
+		if _, ok := firstByKey[keys[i]]; ok {
+			continue
+		}
+		firstByKey[keys[i]] = i
+		pending = append(pending, i)
+	}
+	log.Printf("翻訳キャッシュ: 全%d件中 %d件を翻訳", len(segments), len(pending))
+
+	if len(pending) > 0 {
+		// 実際に翻訳するセグメントだけを文字数上限に計上
+		var source strings.Builder
+		for _, idx := range pending {
+			source.WriteString(segments[idx].Text)
+		}
+		if !canTranslate(source.String()) {
+			return nil, fmt.Errorf("翻訳上限超えました（40万文字/月）")
+		}
+
+		chunks := splitIntoChunks(segments, pending, chunkMaxTokens, chunkContextSize)
+		log.Printf("チャンク翻訳開始: セグメント数=%d, チャンク数=%d", len(pending), len(chunks))
+
+		results, err := translateChunks(ctx, chunks, sourceLang, targetLang, apiKey)
+		if err != nil {
+			return nil, err
+		}
+
+		newEntries := map[string]string{}
+		for _, chunk := range chunks {
+			for i, idx := range chunk.Indices {
+				texts[idx] = results[chunk.Index][i]
+				filled[idx] = true
+				newEntries[keys[idx]] = results[chunk.Index][i]
+			}
+		}
+		segmentCache.PutAll(newEntries)
+
+		// 重複していた原文に翻訳を反映
+		for i := range segments {
+			if !filled[i] {
+				texts[i] = texts[firstByKey[keys[i]]]
+				filled[i] = true
+			}
+		}
+	}
+
+	// 再結合（全セグメントに翻訳が揃っているか照合）
+	translated := make([]SubtitleSegment, 0, len(segments))
+	for i, seg := range segments {
+		if !filled[i] {
+			return nil, fmt.Errorf("セグメント%dの翻訳がありません", i+1)
+		}
+		// 信頼度は原文のものを引き継ぎ、原文の単語情報・候補は持たせない
+		seg.Text = texts[i]
+		seg.Words = nil
+		seg.Alternatives = nil
+		translated = append(translated, seg)
+	}
+
+	return translated, nil
+}
+
+// チャンクを並列数の上限付きで翻訳し、チャンク番号順の結果を返す
+func translateChunks(ctx context.Context, chunks []translationChunk, sourceLang, targetLang, apiKey string) ([][]string, error) {
+	concurrency := chunkConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([][]string, len(chunks))
+	errs := make([]error, len(chunks))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for _, chunk := range chunks {
+		wg.Add(1)
+		go func(chunk translationChunk) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			lines, err := translateChunk(ctx, chunk, sourceLang, targetLang, apiKey)
+			if err != nil {
+				errs[chunk.Index] = fmt.Errorf("チャンク%d翻訳エラー: %v", chunk.Index, err)
+				return
+			}
+			results[chunk.Index] = lines
+		}(chunk)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 翻訳キャッシュの設定（環境変数で上書き可能）
+var (
+	translationCachePath  = getEnv("TRANSLATION_CACHE_PATH", "translation_cache.json")                // キャッシュの保存先
+	glossaryVersion       = getEnv("TRANSLATION_GLOSSARY_VERSION", "v1")                              // 用語集のバージョン（変更するとキャッシュが無効になる）
+	translationCacheFlush = time.Duration(getEnvInt("TRANSLATION_CACHE_FLUSH_SEC", 30)) * time.Second // 追加分をファイルに書き出す間隔（0ならシャットダウン時のみ）
+)
+
+// キャッシュの1エントリ
+type translationCacheEntry struct {
+	Text      string `json:"text"`
+	CreatedAt string `json:"created_at"`
+}
+
+// セグメント翻訳の永続キャッシュ（JSONファイルに保存）
+// 登録はメモリだけに行い、ファイルへは一定間隔とシャットダウン時にまとめて書き出す
+type translationCache struct {
+	mu      sync.Mutex
+	path    string
+	loaded  bool
+	dirty   bool // 書き出していない登録があるか
+	entries map[string]translationCacheEntry
+}
+
+var segmentCache = &translationCache{path: translationCachePath}
+
+// 原文を正規化（前後の空白除去・連続空白の統一）
+func normalizeSourceText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// キャッシュキーを作成（正規化した原文・言語ペア・モデル・用語集バージョン）
+func translationCacheKey(text, sourceLang, targetLang, model string) string {
+	h := sha256.New()
+	for _, part := range []string{normalizeSourceText(text), sourceLang, targetLang, model, glossaryVersion} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// 初回アクセス時にファイルから読み込み（呼び出し側でロック済み）
+func (c *translationCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	c.entries = map[string]translationCacheEntry{}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("翻訳キャッシュ読み込みエラー（空で開始）: %v", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		log.Printf("翻訳キャッシュ解析エラー（空で開始）: %v", err)
+		c.entries = map[string]translationCacheEntry{}
+	}
+}
+
+// キャッシュから翻訳を取得
+func (c *translationCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+
+	entry, ok := c.entries[key]
+	return entry.Text, ok
+}
+
+// 翻訳をまとめて登録（ファイルへの書き出しはFlushで行う）
+func (c *translationCache) PutAll(items map[string]string) {
+	if len(items) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+
+	now := time.Now().Format(time.RFC3339)
+	for key, text := range items {
+		c.entries[key] = translationCacheEntry{Text: text, CreatedAt: now}
+	}
+	c.dirty = true
+}
+
+// 書き出していない登録があればファイルに保存
+func (c *translationCache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+	if err := c.save(); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// 一定間隔でキャッシュを書き出すゴルーチンを開始（ctxがキャンセルされたら終了）
+// 終了時の最後の書き出しは呼び出し側でジョブの終了を待ってから行う
+func startTranslationCacheFlusher(ctx context.Context) {
+	if translationCacheFlush <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(translationCacheFlush)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := segmentCache.Flush(); err != nil {
+					log.Printf("翻訳キャッシュ保存エラー（続行）: %v", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// 一時ファイルに書き込んでから置き換え（書き込み途中で壊れないように）
+func (c *translationCache) save() error {
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("翻訳キャッシュ変換エラー: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".translation_cache-*")
+	if err != nil {
+		return fmt.Errorf("翻訳キャッシュ一時ファイル作成エラー: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("翻訳キャッシュ書き込みエラー: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("翻訳キャッシュ書き込みエラー: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("翻訳キャッシュ保存エラー: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 作業ディレクトリの設定（環境変数で上書き可能）
+var (
+	workDir              = getEnv("WORK_DIR", "work")                                                // ジョブごとのサブフォルダを作る場所
+	deleteAudioOnSuccess = getEnv("DELETE_AUDIO_ON_SUCCESS", "true") == "true"                       // 成功したジョブのファイルをすぐ削除するか
+	failedJobRetention   = time.Duration(getEnvInt("FAILED_JOB_RETENTION_DAYS", 3)) * 24 * time.Hour // 失敗したジョブのファイルを残す期間
+	janitorInterval      = time.Duration(getEnvInt("JANITOR_INTERVAL_MINUTES", 60)) * time.Minute    // 掃除の実行間隔（0なら起動時のみ）
+	minFreeDiskMB        = uint64(getEnvInt("MIN_FREE_DISK_MB", 1024))                               // ダウンロード開始に必要な空き容量
+)
+
+// ジョブ用のサブフォルダを作成してパスを返す
+func createJobDir(videoID string) (string, error) {
+	dir := filepath.Join(workDir, videoID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("作業ディレクトリ作成エラー: %v", err)
+	}
+	return dir, nil
+}
+
+// ジョブ終了時の後片付け（成功時は削除、失敗時は保持期間が過ぎるまで残す）
+func finishJobDir(videoID, dir string) {
+	if videoStatus(videoID) == "completed" && deleteAudioOnSuccess {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("作業ディレクトリ削除エラー: %v", err)
+		}
+		return
+	}
+
+	// 保持期間を終了時刻から数えるため更新日時を更新
+	now := time.Now()
+	os.Chtimes(dir, now, now)
+}
+
+// ダウンロード前に空き容量を確認（requiredMBは見込みのファイルサイズ）
+func checkDiskSpace(requiredMB uint64) error {
+	free, err := freeDiskMB(workDir)
+	if err != nil {
+		// 空き容量を取得できない環境ではチェックしない
+		log.Printf("空き容量取得エラー（チェックを省略）: %v", err)
+		return nil
+	}
+	if free < minFreeDiskMB+requiredMB {
+		return fmt.Errorf("ディスクの空き容量が不足しています（空き%dMB < 必要%dMB）", free, minFreeDiskMB+requiredMB)
+	}
+	return nil
+}
+
+// 保持期間を過ぎたジョブフォルダを定期的に削除するゴルーチンを開始
+// ctxがキャンセルされたら終了し、シャットダウン時はwaitJobsで終了を待つ
+func startJanitor(ctx context.Context) {
+	if janitorInterval <= 0 {
+		cleanupWorkDir()
+		return
+	}
+	goJob(func() {
+		ticker := time.NewTicker(janitorInterval)
+		defer ticker.Stop()
+		for {
+			cleanupWorkDir()
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
+
+// 作業ディレクトリを掃除（処理中のジョブは対象外）
+func cleanupWorkDir() {
+	entries, err := os.ReadDir(workDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("作業ディレクトリ読み込みエラー: %v", err)
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		switch videoStatus(entry.Name()) {
+		case "queued", "processing":
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < failedJobRetention {
+			continue
+		}
+
+		dir := filepath.Join(workDir, entry.Name())
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("作業ディレクトリ削除エラー: %v", err)
+			continue
+		}
+		log.Printf("保持期間切れの作業ディレクトリを削除: %s", dir)
+	}
+}
+
+// 動画のステータスを取得（見つからない場合は空文字）
+func videoStatus(videoID string) string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, video := range videos {
+		if video.ID == videoID {
+			return video.Status
+		}
+	}
+	return ""
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// yt-dlp実行の設定（環境変数で上書き可能）
+var (
+	ytdlpPath            = getEnv("YTDLP_PATH", "yt-dlp")                                           // yt-dlpのバイナリパス
+	ytdlpExtraArgs       = strings.Fields(getEnv("YTDLP_EXTRA_ARGS", ""))                           // すべての実行に追加する引数
+	ytdlpTimeout         = time.Duration(getEnvInt("YTDLP_TIMEOUT_MINUTES", 30)) * time.Minute      // ダウンロードのタイムアウト
+	ytdlpMetadataTimeout = time.Duration(getEnvInt("YTDLP_METADATA_TIMEOUT_SEC", 60)) * time.Second // メタデータ・字幕取得のタイムアウト
+)
+
+// エラーメッセージに含めるstderrの最大長
+const maxYtdlpStderr = 2000
+
+// --newline 指定時の進捗行（例: "[download]  45.3% of 3.45MiB at 1.2MiB/s ETA 00:02"）
+var ytdlpProgressPattern = regexp.MustCompile(`^\[download\]\s+([\d.]+)%`)
+
+// stderrの内容と失敗理由の対応（上から順に判定）
+var ytdlpErrorReasons = []struct {
+	pattern string
+	reason  string
+	message string
+}{
+	{"Private video", "private", "非公開動画のため処理できません"},
+	{"Sign in to confirm your age", "age_restricted", "年齢制限付きの動画のため処理できません"},
+	{"Sign in to confirm", "sign_in_required", "YouTubeにサインインを要求されました（ボット判定・レート制限の可能性）。cookiesやproxyの設定を確認してください"},
+	{"members-only", "members_only", "メンバー限定動画のため処理できません"},
+	{"This live event will begin", "live", "配信予定のライブのため処理できません"},
+	{"Video unavailable", "unavailable", "動画が存在しないか利用できません"},
+}
+
+// yt-dlpのエラーを失敗理由に分類（該当しない場合はok=false）
+func classifyYtdlpError(err error) (reason, message string, ok bool) {
+	for _, r := range ytdlpErrorReasons {
+		if strings.Contains(err.Error(), r.pattern) {
+			return r.reason, r.message, true
+		}
+	}
+	return "", "", false
+}
+
+// yt-dlpの実行エラー（stderrの内容を保持）
+type ytdlpError struct {
+	Err    error
+	Stderr string
+}
+
+func (e *ytdlpError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("yt-dlpエラー: %v", e.Err)
+	}
+	return fmt.Sprintf("yt-dlpエラー: %v: %s", e.Err, e.Stderr)
+}
+
+func (e *ytdlpError) Unwrap() error {
+	return e.Err
+}
+
+// yt-dlpを実行して標準出力を返す
+// onProgressを指定した場合は --newline を付けて進捗行を解析し、標準出力は返さない
+func runYtdlp(ctx context.Context, timeout time.Duration, args []string, onProgress func(float64)) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	fullArgs := append([]string{}, ytdlpExtraArgs...)
+	if onProgress != nil {
+		fullArgs = append(fullArgs, "--newline")
+	}
+	fullArgs = append(fullArgs, args...)
+
+	cmd := exec.CommandContext(ctx, ytdlpPath, fullArgs...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	var stdout bytes.Buffer
+	var pipe io.ReadCloser
+	if onProgress != nil {
+		var err error
+		pipe, err = cmd.StdoutPipe()
+		if err != nil {
+			return nil, &ytdlpError{Err: err}
+		}
+	} else {
+		cmd.Stdout = &stdout
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, &ytdlpError{Err: err}
+	}
+
+	if pipe != nil {
+		scanner := bufio.NewScanner(pipe)
+		// --printのJSON行などは既定の64KBを超えることがある
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			if m := ytdlpProgressPattern.FindStringSubmatch(scanner.Text()); m != nil {
+				if pct, err := strconv.ParseFloat(m[1], 64); err == nil {
+					onProgress(pct)
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			log.Printf("yt-dlp出力の読み込みエラー（残りは読み捨て）: %v", err)
+		}
+		// 読み残しがあるとyt-dlpが書き込みで止まりWaitが返らないため、最後まで読み捨てる
+		io.Copy(io.Discard, pipe)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("タイムアウト（%v）: %v", timeout, err)
+		}
+		return nil, &ytdlpError{Err: err, Stderr: summarizeStderr(stderr.String())}
+	}
+	return stdout.Bytes(), nil
+}
+
+// stderrからエラー行を優先して取り出し、長すぎる場合は末尾を残して切り詰める
+func summarizeStderr(stderr string) string {
+	var errorLines []string
+	for _, line := range strings.Split(stderr, "\n") {
+		if strings.HasPrefix(line, "ERROR:") {
+			errorLines = append(errorLines, strings.TrimSpace(line))
+		}
+	}
+
+	summary := strings.TrimSpace(stderr)
+	if len(errorLines) > 0 {
+		summary = strings.Join(errorLines, "\n")
+	}
+	if len(summary) > maxYtdlpStderr {
+		summary = "..." + summary[len(summary)-maxYtdlpStderr:]
+	}
+	return summary
+}
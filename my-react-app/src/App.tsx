@@ -16,10 +16,23 @@ interface Transcript {
   created_at: string
 }
 
+interface VideoMetadata {
+  title: string
+  channel: string
+  duration: number
+  thumbnail: string
+  upload_date: string
+  language: string
+}
+
 interface Video {
   id: string
   youtube_url: string
+  metadata?: VideoMetadata
   status: string
+  progress: number
+  error?: string
+  error_reason?: string
   created_at: string
   update_at: string
 }
@@ -159,7 +172,12 @@ function App() {
       if (response.ok) {
         return response.json()
       } else {
-        throw new Error('サーバーエラー')
+        // 事前チェックで拒否された場合は理由を表示
+        return response.json()
+          .catch(() => ({}))
+          .then(body => {
+            throw new Error(body.error || 'サーバーエラー')
+          })
       }
     })
     .then(data => {
@@ -264,9 +282,17 @@ function App() {
                     backgroundColor: video.status === 'completed' ? '#f0fff0' : '#fff5ee'
                   }}
                 >
+                  {video.metadata && (
+                    <div><strong>タイトル:</strong> {video.metadata.title}（{video.metadata.channel}）</div>
+                  )}
                   <div><strong>ID:</strong> {video.id}</div>
                   <div><strong>URL:</strong> <a href={video.youtube_url} target="_blank" rel="noopener noreferrer">{video.youtube_url}</a></div>
-                  <div><strong>ステータス:</strong> <span style={{color: video.status === 'completed' ? 'green' : 'orange'}}>{video.status}</span></div>
+                  <div><strong>ステータス:</strong> <span style={{color: video.status === 'completed' ? 'green' : 'orange'}}>{video.status}</span>
+                    {video.status === 'processing' && video.progress > 0 && ` (${video.progress.toFixed(1)}%)`}
+                  </div>
+                  {video.error && (
+                    <div style={{color: 'red'}}><strong>エラー:</strong> {video.error}{video.error_reason && ` (${video.error_reason})`}</div>
+                  )}
                   <div><strong>作成日時:</strong> {new Date(video.created_at).toLocaleString()}</div>
                   
                   {video.status === 'completed' && (